@@ -0,0 +1,355 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		 http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bien
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Account is the cash account of a company, stored under acct:<company>
+type Account struct {
+	ID          string   `json:"id"`
+	Company     string   `json:"company"`
+	CashBalance float64  `json:"cashBalance"`
+	AssetsIds   []string `json:"assetIds"`
+	SchemaVersion int    `json:"schemaVersion"`
+}
+
+func init() {
+	registerFunctions(
+		Function{Name: "create_account", Kind: KindInvoke, Description: "Opens the cash account of a company",
+			Args: []ArgSpec{{Name: "account", Type: ArgJSON, Description: "company and cashBalance", Model: Account{}}},
+			Errors: []string{CodeAccountExists, CodeCompanyNotFound, CodeCompanySuspended},
+			Roles: []string{RoleIssuer, RoleBuyer},
+			Handler: (*BienChaincode).create_account},
+		Function{Name: "deposit", Kind: KindInvoke, Description: "Adds cash to an account",
+			Args: []ArgSpec{{Name: "company", Type: ArgString}, {Name: "amount", Type: ArgNumber}},
+			Errors: []string{CodeAccountNotFound},
+			Roles: []string{RoleAdmin},
+			Handler: (*BienChaincode).deposit},
+		Function{Name: "withdraw", Kind: KindInvoke, Description: "Takes cash out of an account",
+			Args: []ArgSpec{{Name: "company", Type: ArgString}, {Name: "amount", Type: ArgNumber}},
+			Errors: []string{CodeAccountNotFound, CodeInsufficientFunds},
+			Roles: []string{RoleIssuer, RoleBuyer},
+			Handler: (*BienChaincode).withdraw},
+		Function{Name: "buy_goods", Kind: KindInvoke, Description: "Transfers goods against payment from the buyer's account, companies trade with open_trade and accept_trade",
+			Args: []ArgSpec{{Name: "transaction", Type: ArgJSON, Description: "gdsid, fromCompany, toCompany, quantity and unit price", Model: Transaction{}}},
+			Errors: []string{CodeGoodsNotFound, CodeGoodsInactive, CodeNotOwner, CodeInsufficientQuantity,
+				CodeAccountNotFound, CodeInsufficientFunds, CodeCompanyNotFound, CodeCompanySuspended},
+			Roles: []string{RoleAdmin},
+			Handler: (*BienChaincode).buyGoods},
+		Function{Name: "get_account", Kind: KindQuery, Description: "The cash account of a company",
+			Args: []ArgSpec{{Name: "company", Type: ArgString}},
+			Returns: Account{},
+			Errors: []string{CodeAccountNotFound},
+			Roles: []string{RoleIssuer, RoleBuyer, RoleAuditor},
+			Handler: (*BienChaincode).get_account},
+	)
+}
+
+// create_account - invoke function to open the cash account of a company
+func (t *BienChaincode) create_account(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+
+	/*		0
+		json
+		{
+			"company": "company1",
+			"cashBalance": 1000.00		// optional opening balance, admins only, companies fund theirs through deposit
+		}
+	*/
+
+	var account Account
+	err := json.Unmarshal([]byte(args[0]), &account)
+	if err != nil {
+		fmt.Println(err)
+		return nil, argError("account", "Invalid account")
+	}
+	if account.Company == "" {
+		return nil, argError("account", "Account needs a company")
+	}
+	if account.CashBalance < 0 {
+		return nil, argError("account", "Opening balance cannot be negative")
+	}
+	caller, err := callerIdentity(stub)
+	if err != nil {
+		return nil, err
+	}
+	err = requireCompany(caller, account.Company)
+	if err != nil {
+		return nil, err
+	}
+	if account.CashBalance != 0 && !caller.hasRole(RoleAdmin) {
+		return nil, newError(CodeForbidden, "Only an admin opens an account with a balance, "+account.Company+" starts at 0")
+	}
+	err = checkCompanies(stub, account.Company)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := stub.GetState(accountPrefix + account.Company)
+	if err != nil {
+		return nil, newError(CodeLedger, "Error retrieving account for "+account.Company)
+	}
+	if existing != nil {
+		return nil, newError(CodeAccountExists, "Account for "+account.Company+" already exists")
+	}
+	account.ID = accountPrefix + account.Company
+
+	// The company may already own goods issued before it had an account
+	allGDs, err := GetAllgoods(stub)
+	if err != nil {
+		return nil, err
+	}
+	account.AssetsIds = nil
+	for _, gd := range allGDs {
+		if ownedBy(gd, account.Company) > 0 {
+			account.AssetsIds = append(account.AssetsIds, gd.GDSID)
+		}
+	}
+
+	err = putAccount(stub, account)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Println("Created account " + account.ID)
+	return nil, nil
+}
+
+// get_account - query function to read the account of a company
+func (t *BienChaincode) get_account(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// auditors see every account, companies their own
+	caller, err := callerIdentity(stub)
+	if err != nil {
+		return nil, err
+	}
+	if !caller.hasRole(RoleAuditor) {
+		err = requireCompany(caller, args[0])
+		if err != nil {
+			return nil, err
+		}
+	}
+	account, err := getAccount(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(&account)
+}
+
+// deposit - invoke function to credit cash to the account of a company
+func (t *BienChaincode) deposit(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	//   0          1
+	// company   amount
+	company, amount, err := parseCashArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	account, err := getAccount(stub, company)
+	if err != nil {
+		return nil, err
+	}
+	account.CashBalance += amount
+	return nil, putAccount(stub, account)
+}
+
+// withdraw - invoke function to debit cash from the account of a company
+func (t *BienChaincode) withdraw(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	//   0          1
+	// company   amount
+	company, amount, err := parseCashArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	caller, err := callerIdentity(stub)
+	if err != nil {
+		return nil, err
+	}
+	err = requireCompany(caller, company)
+	if err != nil {
+		return nil, err
+	}
+	account, err := getAccount(stub, company)
+	if err != nil {
+		return nil, err
+	}
+	if account.CashBalance < amount {
+		return nil, newError(CodeInsufficientFunds, "Insufficient funds in account of "+company)
+	}
+	account.CashBalance -= amount
+	return nil, putAccount(stub, account)
+}
+
+// buyGoods - invoke function to transfer goods and pay for them in the same transaction
+func (t *BienChaincode) buyGoods(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+
+	/*		0
+		json
+		{
+			"gdsid": "COMPANA10000010",
+			"fromCompany": "company2",	// seller
+			"toCompany": "company1",	// buyer
+			"quantity": 3,
+			"price": 12.50				// price of a single unit
+		}
+	*/
+
+	var tr Transaction
+	err := json.Unmarshal([]byte(args[0]), &tr)
+	if err != nil {
+		fmt.Println(err)
+		return nil, argError("transaction", "Invalid goods purchase")
+	}
+	// only admins settle a sale directly, neither side may set the price and move the other's goods or cash alone:
+	// buyer and seller agree on a price with open_trade and accept_trade
+	err = purchase(stub, &tr)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("%s bought %d of goods %s from %s\n", tr.ToCompany, tr.Quantity, tr.GDSID, tr.FromCompany)
+	return nil, nil
+}
+
+// purchase - moves the goods of a transaction to toCompany and pays fromCompany for them
+func purchase(stub shim.ChaincodeStubInterface, tr *Transaction) error {
+	if tr.Price <= 0 {
+		return newError(CodeInvalidArgument, "Purchase needs a positive price")
+	}
+
+	goods, err := prepareTransfer(stub, tr)
+	if err != nil {
+		return err
+	}
+	err = settle(stub, tr.ToCompany, tr.FromCompany, tr.Price*float64(tr.Quantity))
+	if err != nil {
+		return err
+	}
+	return commitTransfer(stub, goods, tr)
+}
+
+// settle - moves amount from the buyer's account to the seller's account
+func settle(stub shim.ChaincodeStubInterface, buyer string, seller string, amount float64) error {
+	buyerAccount, err := getAccount(stub, buyer)
+	if err != nil {
+		return err
+	}
+	sellerAccount, err := getAccount(stub, seller)
+	if err != nil {
+		return err
+	}
+	if buyerAccount.CashBalance < amount {
+		return newError(CodeInsufficientFunds, "Insufficient funds in account of "+buyer)
+	}
+
+	buyerAccount.CashBalance -= amount
+	sellerAccount.CashBalance += amount
+	err = putAccount(stub, buyerAccount)
+	if err != nil {
+		return err
+	}
+	return putAccount(stub, sellerAccount)
+}
+
+// syncAssets - brings the asset lists of the companies' accounts in line with the goods ownership
+func syncAssets(stub shim.ChaincodeStubInterface, goods Goods, companies ...string) error {
+	for _, company := range companies {
+		accountBytes, err := stub.GetState(accountPrefix + company)
+		if err != nil {
+			return newError(CodeLedger, "Error retrieving account for "+company)
+		}
+		if accountBytes == nil {
+			// companies without an account only exist in the goods owner list
+			continue
+		}
+		account, err := getAccount(stub, company)
+		if err != nil {
+			return err
+		}
+
+		var assets []string
+		for _, id := range account.AssetsIds {
+			if id != goods.GDSID {
+				assets = append(assets, id)
+			}
+		}
+		if ownedBy(goods, company) > 0 {
+			assets = append(assets, goods.GDSID)
+		}
+		account.AssetsIds = assets
+
+		err = putAccount(stub, account)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ownedBy - quantity of the goods held by a company
+func ownedBy(goods Goods, company string) int {
+	for _, owner := range goods.Owners {
+		if owner.Company == company {
+			return owner.Quantity
+		}
+	}
+	return 0
+}
+
+// parseCashArgs - the company and positive amount of deposit and withdraw, route has checked there are two
+func parseCashArgs(args []string) (string, float64, error) {
+	amount, err := strconv.ParseFloat(args[1], 64)
+	if err != nil || amount <= 0 {
+		return "", 0, argError("amount", "Amount must be a positive number")
+	}
+	return args[0], amount, nil
+}
+
+// getAccount - reads the account of a company
+func getAccount(stub shim.ChaincodeStubInterface, company string) (Account, error) {
+	var account Account
+
+	accountBytes, err := stub.GetState(accountPrefix + company)
+	if err != nil {
+		fmt.Println("Error retrieving account " + company)
+		return account, newError(CodeLedger, "Error retrieving account for "+company)
+	}
+	if accountBytes == nil {
+		return account, newError(CodeAccountNotFound, "No account for "+company)
+	}
+	err = unmarshalRecord(kindAccount, accountBytes, &account)
+	if err != nil {
+		fmt.Println("Error unmarshalling account " + company)
+		return account, newError(CodeCorruptRecord, "Error unmarshalling account for "+company)
+	}
+	return account, nil
+}
+
+// putAccount - writes the account back under its company
+func putAccount(stub shim.ChaincodeStubInterface, account Account) error {
+	account.SchemaVersion = schemaVersions[kindAccount]
+	accountBytes, err := json.Marshal(&account)
+	if err != nil {
+		fmt.Println("Error marshalling account")
+		return newError(CodeInternal, "Error marshalling account for "+account.Company)
+	}
+	err = stub.PutState(accountPrefix+account.Company, accountBytes)
+	if err != nil {
+		fmt.Println("Error writing account")
+		return newError(CodeLedger, "Error writing account for "+account.Company)
+	}
+	return nil
+}
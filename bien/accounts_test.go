@@ -0,0 +1,190 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		 http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bien
+
+import (
+	"testing"
+)
+
+var accountCases = register([]funcCase{
+	{name: "opens an account at 0", fn: "create_account", as: asDave,
+		args: []string{`{"company":"company4"}`},
+		check: func(l *testLedger, result []byte) {
+			account := l.account("company4")
+			if account.ID != "acct:company4" || account.CashBalance != 0 || len(account.AssetsIds) != 0 {
+				l.t.Errorf("opened %+v", account)
+			}
+		}},
+	{name: "opens an account with a balance for an admin", fn: "create_account", as: asAdmin,
+		args: []string{`{"company":"company4","cashBalance":50}`},
+		check: func(l *testLedger, result []byte) {
+			checkBalance(l, "company4", 50)
+		}},
+	{name: "lists the goods the company already owns", fn: "create_account", as: asDave,
+		setup: func(l *testLedger) {
+			l.must(asCarol, "transfer_goods", `{"gdsid":"{gdsid}","fromCompany":"company2","toCompany":"company4","quantity":2}`)
+		},
+		args: []string{`{"company":"company4"}`},
+		check: func(l *testLedger, result []byte) {
+			if assets := l.account("company4").AssetsIds; len(assets) != 1 || assets[0] != l.vars["gdsid"] {
+				l.t.Errorf("assets of company4 are %v", assets)
+			}
+		}},
+	{name: "opens one account per company", fn: "create_account", as: asAlice,
+		args: []string{`{"company":"company1"}`},
+		code: CodeAccountExists},
+	{name: "only opens the caller's company's account", fn: "create_account", as: asDave,
+		args: []string{`{"company":"company3"}`},
+		code: CodeForbidden},
+	{name: "needs a company", fn: "create_account", as: asAdmin,
+		args: []string{`{"cashBalance":50}`},
+		code: CodeInvalidArgument},
+	{name: "needs a registered company", fn: "create_account", as: asAdmin,
+		args: []string{`{"company":"ghost"}`},
+		code: CodeCompanyNotFound},
+	{name: "needs an active company", fn: "create_account", as: asAdmin,
+		args: []string{`{"company":"company3"}`},
+		code: CodeCompanySuspended},
+	{name: "does not open in debt", fn: "create_account", as: asAdmin,
+		args: []string{`{"company":"company4","cashBalance":-50}`},
+		code: CodeInvalidArgument},
+	{name: "leaves the opening balance to admins", fn: "create_account", as: asDave,
+		args: []string{`{"company":"company4","cashBalance":50}`},
+		code: CodeForbidden},
+
+	{name: "credits an account", fn: "deposit", as: asAdmin,
+		args: []string{"company1", "50"},
+		check: func(l *testLedger, result []byte) {
+			checkBalance(l, "company1", 150)
+		}},
+	{name: "needs an account", fn: "deposit", as: asAdmin,
+		args: []string{"company4", "50"},
+		code: CodeAccountNotFound},
+	{name: "needs a positive amount", fn: "deposit", as: asAdmin,
+		args: []string{"company1", "-50"},
+		code: CodeInvalidArgument},
+	{name: "is for admins", fn: "deposit", as: asAlice,
+		args: []string{"company1", "50"},
+		code: CodeForbidden},
+
+	{name: "debits an account", fn: "withdraw", as: asAlice,
+		args: []string{"company1", "30"},
+		check: func(l *testLedger, result []byte) {
+			checkBalance(l, "company1", 70)
+		}},
+	{name: "needs an account", fn: "withdraw", as: asDave,
+		args: []string{"company4", "30"},
+		code: CodeAccountNotFound},
+	{name: "does not overdraw", fn: "withdraw", as: asAlice,
+		args: []string{"company1", "500"},
+		code: CodeInsufficientFunds},
+	{name: "only debits the caller's account", fn: "withdraw", as: asAlice,
+		args: []string{"company2", "30"},
+		code: CodeForbidden},
+
+	{name: "reads the caller's account", fn: "get_account", as: asAlice,
+		args: []string{"company1"},
+		check: func(l *testLedger, result []byte) {
+			var account Account
+			l.decode(result, &account)
+			if account.Company != "company1" || account.CashBalance != 100 {
+				l.t.Errorf("get_account returned %+v", account)
+			}
+		}},
+	{name: "reads any account for an auditor", fn: "get_account", as: asAudrey,
+		args: []string{"company2"}},
+	{name: "needs an account", fn: "get_account", as: asDave,
+		args: []string{"company4"},
+		code: CodeAccountNotFound},
+	{name: "does not read other companies' accounts", fn: "get_account", as: asAlice,
+		args: []string{"company2"},
+		code: CodeForbidden},
+
+	{name: "moves the goods and the payment", fn: "buy_goods", as: asAdmin,
+		args: []string{`{"gdsid":"{gdsid}","fromCompany":"company2","toCompany":"company1","quantity":2,"price":10}`},
+		event: EventGoodsSold,
+		check: func(l *testLedger, result []byte) {
+			checkOwners(l, l.vars["gdsid"], owners(Owner{"company2", 8}, Owner{"company1", 2}))
+			checkBalance(l, "company1", 80)
+			checkBalance(l, "company2", 120)
+			settlement := l.lastEvent().Settlement
+			if settlement == nil || *settlement != (Settlement{Buyer: "company1", Seller: "company2", Amount: 20}) {
+				l.t.Errorf("settlement is %+v", settlement)
+			}
+		}},
+	{name: "needs existing goods", fn: "buy_goods", as: asAdmin,
+		args: []string{`{"gdsid":"nothere","fromCompany":"company2","toCompany":"company1","quantity":2,"price":10}`},
+		code: CodeGoodsNotFound},
+	{name: "does not sell cancelled goods", fn: "buy_goods", as: asAdmin,
+		setup: cancelGoods,
+		args: []string{`{"gdsid":"{gdsid}","fromCompany":"company2","toCompany":"company1","quantity":2,"price":10}`},
+		code: CodeGoodsInactive},
+	{name: "needs the seller to own the goods", fn: "buy_goods", as: asAdmin,
+		args: []string{`{"gdsid":"{gdsid}","fromCompany":"company1","toCompany":"company2","quantity":2,"price":10}`},
+		code: CodeNotOwner},
+	{name: "does not sell more than the seller holds", fn: "buy_goods", as: asAdmin,
+		args: []string{`{"gdsid":"{gdsid}","fromCompany":"company2","toCompany":"company1","quantity":11,"price":1}`},
+		code: CodeInsufficientQuantity},
+	{name: "needs the buyer's account", fn: "buy_goods", as: asAdmin,
+		args: []string{`{"gdsid":"{gdsid}","fromCompany":"company2","toCompany":"company4","quantity":1,"price":10}`},
+		code: CodeAccountNotFound},
+	{name: "needs the buyer to afford the goods", fn: "buy_goods", as: asAdmin,
+		args: []string{`{"gdsid":"{gdsid}","fromCompany":"company2","toCompany":"company1","quantity":2,"price":100}`},
+		code: CodeInsufficientFunds},
+	{name: "needs a registered buyer", fn: "buy_goods", as: asAdmin,
+		args: []string{`{"gdsid":"{gdsid}","fromCompany":"company2","toCompany":"ghost","quantity":1,"price":10}`},
+		code: CodeCompanyNotFound},
+	{name: "needs an active buyer", fn: "buy_goods", as: asAdmin,
+		args: []string{`{"gdsid":"{gdsid}","fromCompany":"company2","toCompany":"company3","quantity":1,"price":10}`},
+		code: CodeCompanySuspended},
+	{name: "is for admins, companies trade", fn: "buy_goods", as: asCarol,
+		args: []string{`{"gdsid":"{gdsid}","fromCompany":"company2","toCompany":"company1","quantity":2,"price":10}`},
+		code: CodeForbidden},
+	{name: "needs a price", fn: "buy_goods", as: asAdmin,
+		args: []string{`{"gdsid":"{gdsid}","fromCompany":"company2","toCompany":"company1","quantity":2}`},
+		code: CodeInvalidArgument},
+})
+
+func TestAccountFunctions(t *testing.T) {
+	runCases(t, accountCases)
+}
+
+func TestParseCashArgs(t *testing.T) {
+	for _, c := range []struct {
+		args    []string
+		company string
+		amount  float64
+		fails   bool
+	}{
+		{args: []string{"company1", "50"}, company: "company1", amount: 50},
+		{args: []string{"company1", "0.25"}, company: "company1", amount: 0.25},
+		{args: []string{"company1", "0"}, fails: true},
+		{args: []string{"company1", "-50"}, fails: true},
+		{args: []string{"company1", "fifty"}, fails: true},
+	} {
+		company, amount, err := parseCashArgs(c.args)
+		if c.fails != (err != nil) || company != c.company || amount != c.amount {
+			t.Errorf("parseCashArgs(%q) = %q, %v, %v", c.args, company, amount, err)
+		}
+	}
+}
+
+func TestOwnedBy(t *testing.T) {
+	goods := Goods{Owners: []Owner{{"company2", 8}, {"company1", 2}}}
+	for company, want := range map[string]int{"company1": 2, "company2": 8, "company3": 0} {
+		if got := ownedBy(goods, company); got != want {
+			t.Errorf("ownedBy(%s) = %d, want %d", company, got, want)
+		}
+	}
+}
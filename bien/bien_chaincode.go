@@ -0,0 +1,960 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		 http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bien
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"encoding/json"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// BienChaincode is  a Chaincode for bien application implementation
+type BienChaincode struct {
+}
+const (
+	millisPerSecond     = int64(time.Second / time.Millisecond)
+	nanosPerMillisecond = int64(time.Millisecond / time.Nanosecond)
+)
+func generateCUSIPSuffix(issueDate string, days int) (string, error) {
+
+	t, err := msToTime(issueDate)
+	if err != nil {
+		return "", err
+	}
+
+	maturityDate := t.AddDate(0, 0, days)
+	month := int(maturityDate.Month())
+	day := maturityDate.Day()
+
+	suffix := seventhDigit[month] + eigthDigit[day]
+	return suffix, nil
+
+}
+
+func msToTime(ms string) (time.Time, error) {
+	msInt, err := strconv.ParseInt(ms, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Unix(msInt/millisPerSecond,
+		(msInt%millisPerSecond)*nanosPerMillisecond), nil
+}
+var openTradesStr = "_opentrades"				//name for the key/value that will store all open trades
+var goodsPrefix = "goods:"						//legacy goods key, records now live under goodsObjectType composite keys
+var transferPrefix = "transfer:"				//transfer records are kept under transfer:<GDSID>:<sequence>
+var accountPrefix = "acct:"
+type Owner struct {
+	Company  string    `json:"company"`
+	Quantity int       `json:"quantity"`
+}
+
+type Goods struct{
+		GDSID string `json:"goodsId"`
+		Name string `json:"name"`	
+		Price float64 `json:"price"`
+		Postage float64 `json:"postage"`
+		Owners    []Owner `json:"owner"`
+	    Issuer    string  `json:"issuer"`
+	    State string `json:"state"`
+		LegacyState string `json:"legacyState,omitempty"`		//free text state the goods had before the lifecycle, see upgradeGoodsStates
+		OrderedBy string `json:"orderedBy,omitempty"`		//company that ordered the goods, it reports them delivered
+		Quantity  int     `json:"quantity"`		//total issued quantity, the owners' quantities never add up to more
+		Identifiers map[string]string `json:"identifiers,omitempty"`	//GTIN/SSCC identifiers by scheme, see idschemes.go
+		SchemaVersion int `json:"schemaVersion"`		//see schema.go
+}
+
+type Transaction struct {
+	GDSID       string   `json:"gdsid"`
+	FromCompany string   `json:"fromCompany"`
+	ToCompany   string   `json:"toCompany"`
+	Postage     float64  `json:"postage"`
+	Quantity    int      `json:"quantity"`
+	Price       float64  `json:"price"`		//unit price paid by toCompany, only set on purchases
+	Sequence    int      `json:"sequence"`
+	SchemaVersion int    `json:"schemaVersion"`
+}
+
+var logger = shim.NewLogger("SimpleChaincode")
+
+func init() {
+	registerFunctions(
+		Function{Name: "init", Kind: KindInvoke, Description: "Resets the test variable abc and the order book",
+			Args: []ArgSpec{{Name: "value", Type: ArgInteger, Description: "initial value of abc"}},
+			Roles: []string{RoleAdmin},
+			Handler: func(t *BienChaincode, stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+				return t.Init(stub, "init", args)
+			}},
+		Function{Name: "add_goods", Kind: KindInvoke, Description: "Issues goods, returns the new GDSID",
+			Args: []ArgSpec{{Name: "goods", Type: ArgJSON, Description: "the goods to issue, see issueCommercialGoods", Model: Goods{}}},
+			Returns: "", Errors: []string{CodeInvalidState, CodeGoodsExists, CodeIdentifierTaken, CodeIdentifiersExhausted,
+				CodeCompanyNotFound, CodeCompanySuspended},
+			Roles: []string{RoleIssuer},
+			Handler: (*BienChaincode).issueCommercialGoods,
+			Overloads: []Function{{
+				Description: "add_goods of the Bien chaincode variants",
+				Args: []ArgSpec{{Name: "name", Type: ArgString}, {Name: "owner", Type: ArgString},
+					{Name: "state", Type: ArgString}, {Name: "price", Type: ArgNumber}, {Name: "postage", Type: ArgNumber}},
+				Returns: "", Errors: []string{CodeInvalidState, CodeGoodsExists, CodeIdentifierTaken, CodeIdentifiersExhausted,
+				CodeCompanyNotFound, CodeCompanySuspended},
+				Handler: (*BienChaincode).add_bien}}},
+		Function{Name: "transfer_goods", Kind: KindInvoke, Description: "Moves units of goods between companies",
+			Args: []ArgSpec{{Name: "transaction", Type: ArgJSON, Description: "gdsid, fromCompany, toCompany and quantity", Model: Transaction{}}},
+			Errors: []string{CodeGoodsNotFound, CodeGoodsInactive, CodeNotOwner, CodeInsufficientQuantity, CodeCompanyNotFound, CodeCompanySuspended},
+			Roles: []string{RoleIssuer, RoleBuyer},
+			Handler: (*BienChaincode).transferGoods},
+		Function{Name: "change_state", Kind: KindInvoke, Description: "Moves goods to the next state of their lifecycle",
+			Args: []ArgSpec{{Name: "gdsid", Type: ArgString}, {Name: "state", Type: ArgString},
+				{Name: "company", Type: ArgString, Optional: true, Description: "the company ordering the goods, only with ordered"}},
+			Errors: []string{CodeGoodsNotFound, CodeInvalidState, CodeInvalidStateTransition, CodeStatePreconditionFailed,
+				CodeCompanyNotFound, CodeCompanySuspended},
+			Roles: []string{RoleIssuer, RoleBuyer, RoleShipper},
+			Handler: (*BienChaincode).change_state},
+		Function{Name: "set_price", Kind: KindInvoke, Description: "Changes the price of goods, by their issuer",
+			Args: []ArgSpec{{Name: "gdsid", Type: ArgString}, {Name: "price", Type: ArgNumber}},
+			Errors: []string{CodeGoodsNotFound, CodeGoodsInactive, CodeCompanyNotFound, CodeCompanySuspended},
+			Roles: []string{RoleIssuer},
+			Handler: (*BienChaincode).set_price},
+		Function{Name: "GetAllgoods", Kind: KindQuery, Description: "Every goods record", Returns: []Goods{},
+			Handler: (*BienChaincode).get_all_goods},
+		Function{Name: "GetGD", Kind: KindQuery, Description: "Goods by GDSID, GTIN or SSCC",
+			Args: []ArgSpec{{Name: "id", Type: ArgString}},
+			Returns: Goods{},
+			Errors: []string{CodeGoodsNotFound, CodeInvalidIdentifier},
+			Handler: (*BienChaincode).get_gd},
+	)
+}
+
+// Init resets all the things
+func (t *BienChaincode) Init(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
+	fmt.Printf("hello init chaincode, it is for testing")
+	var Aval int
+	var err error
+    logger.Warning("init logger should be 1 string") 
+	if len(args) != 1 {
+		return nil, newError(CodeInvalidArgument, "Incorrect number of arguments. Expecting 1")
+	}
+
+	// Initialize the chaincode
+	Aval, err = strconv.Atoi(args[0])
+	if err != nil {
+		return nil, argError("value", "Expecting integer value for asset holding")
+	}
+
+	// The deployer administers the chaincode, it binds the companies and roles of everybody else
+	caller, err := callerIdentity(stub)
+	if err != nil {
+		return nil, err
+	}
+	if caller.Name != anonymous && !caller.hasRole(RoleAdmin) {
+		caller.Roles = append(caller.Roles, RoleAdmin)
+		err = putIdentity(stub, caller)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Write the state to the ledger
+	err = putEntry(stub, KVEntry{Key: "abc", Value: strconv.Itoa(Aval), Owner: caller.Name})	//making a test var "abc", I find it handy to read/write to it right away to test the network
+	if err != nil {
+		return nil, err
+	}
+	logger.Infof("init logger arg0=%v", args[0])
+
+	var trades AllTrades
+	err = putOpenTrades(stub, trades)								//clear the order book
+	if err != nil {
+		return nil, err
+	}
+	
+	return nil, nil
+}
+
+// Invoke isur entry point to invoke a chaincode function
+func (t *BienChaincode) Invoke(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
+	fmt.Println("invoke is running " + function)
+	return t.route(stub, KindInvoke, function, args)
+}
+
+// Query is our entry point for queries
+func (t *BienChaincode) Query(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
+	fmt.Println("query is running " + function)
+
+	// Older clients send any function name with the query name in args[0],
+	// or "query" with a key in args[0] to read it
+	if _, ok := functions[function]; !ok && len(args) > 0 {
+		if fn, ok := functions[args[0]]; ok && fn.Kind == KindQuery {
+			return t.route(stub, KindQuery, args[0], args[1:])
+		}
+		if function == "query" {
+			return t.route(stub, KindQuery, "read", args)
+		}
+	}
+	return t.route(stub, KindQuery, function, args)
+}
+
+// get_all_goods - query function returning every goods record
+func (t *BienChaincode) get_all_goods(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	fmt.Println("Getting all GDs")
+	allGDs, err := GetAllgoods(stub)
+	if err != nil {
+		fmt.Println("Error from getallgoods")
+		return nil, err
+	}
+	allGDsBytes, err := json.Marshal(&allGDs)
+	if err != nil {
+		fmt.Println("Error marshalling allGDs")
+		return nil, err
+	}
+	fmt.Println("All success, returning allGDs")
+	return allGDsBytes, nil
+}
+
+// get_gd - query function returning the goods registered under a GDSID, GTIN or SSCC
+func (t *BienChaincode) get_gd(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	fmt.Println("Getting particular GD")
+	gd, err := GetGD(args[0], stub)
+	if err != nil {
+		fmt.Println("Error Getting particular GD")
+		return nil, err
+	}
+	gdBytes, err := json.Marshal(&gd)
+	if err != nil {
+		fmt.Println("Error marshalling the gd")
+		return nil, err
+	}
+	fmt.Println("All success, returning the gd")
+	return gdBytes, nil
+}
+
+func (t *BienChaincode) issueCommercialGoods(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+
+	/*		0
+	
+	    GDSID int64 `json:"goodsId"`
+		name string `json:"name"`	
+		price int `json:"price"`
+		postage int `json:"postage"`
+		Owners    []Owner `json:"owner"`
+	    Issuer    string  `json:"issuer"`
+	    state string `json:"state"`
+		
+		json
+	  	{
+			"name":  "string",
+			"price": 0.00,
+			"postage": 7.5,
+			"owners": [ // This one is not required
+				{
+					"company": "company1",
+					"quantity": 5
+				},
+				{
+					"company": "company3",
+					"quantity": 3
+				},
+				{
+					"company": "company4",
+					"quantity": 2
+				}
+			],				
+			"issuer":"company2",
+			"state":"new",
+			"quantity": 10
+
+		}
+	*/
+
+	var goods Goods
+	var err error
+	//var account Account
+	timestamp, err := txTimestamp(stub)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Println("Unmarshalling goods")
+	err = json.Unmarshal([]byte(args[0]), &goods)
+	if err != nil {
+		fmt.Println(err)
+		return nil, argError("goods", "Invalid commercial goods issue")
+	}
+
+	fmt.Println(" goods",goods)
+	// Every goods record starts its lifecycle as new
+	if goods.State != "" && normalizeState(goods.State) != StateNew {
+		return nil, stateError(CodeInvalidState, goods, "", goods.State, []string{StateNew},
+			"Goods must be issued in state new")
+	}
+	goods.State = StateNew
+
+	if goods.Issuer == "" {
+		return nil, argError("goods", "Goods issue needs an issuer")
+	}
+	// goods may be issued without a price, set_price gives them one before they are listed
+	if goods.Price < 0 {
+		return nil, argError("price", "Price must not be negative")
+	}
+	if goods.Postage < 0 {
+		return nil, argError("postage", "Postage must not be negative")
+	}
+	caller, err := callerIdentity(stub)
+	if err != nil {
+		return nil, err
+	}
+	err = requireCompany(caller, goods.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	issuer, err := activeCompany(stub, goods.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	// Older clients only send the owners list, the issued quantity is then what they add up to
+	if goods.Quantity == 0 {
+		goods.Quantity = totalOwned(goods)
+	}
+	if goods.Quantity == 0 {
+		goods.Quantity = 1
+	}
+	if goods.Quantity < 0 {
+		return nil, argError("goods", "Issued quantity must be positive")
+	}
+
+	// Set the issuer to be the owner of all quantity
+	var owner Owner
+	owner.Company = goods.Issuer
+	owner.Quantity = goods.Quantity
+
+	goods.Owners = []Owner{owner}
+
+	goods.GDSID, err = newGDSID(stub, issuer.ShortCode, timestamp)
+	if err != nil {
+		fmt.Println("Error generating gdsid")
+		return nil, err
+	}
+	
+	fmt.Println("Getting State on goods " + goods.GDSID)
+	exists, err := goodsExists(stub, goods.GDSID)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		// the issuer sequence never repeats, so this means the ledger was written around it
+		fmt.Println("GDSID exists")
+		return nil, newError(CodeGoodsExists, "GDSID "+goods.GDSID+" already exists")
+	}
+
+	fmt.Println("GDSID does not exist, creating it")
+	err = assignSchemeID(stub, &goods)
+	if err != nil {
+		return nil, err
+	}
+	err = putGoods(stub, goods, "issue")
+	if err != nil {
+		fmt.Println("Error issuing goods")
+		return nil, err
+	}
+
+	err = syncAssets(stub, goods, goods.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	goods.SchemaVersion = schemaVersions[kindGoods]		//as putGoods wrote it
+	err = emitGoodsEvent(stub, EventGoodsIssued, GoodsEvent{GDSID: goods.GDSID, New: goods})
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("Issue commercial goods %+v\n", goods)
+	return []byte(goods.GDSID), nil
+}
+
+// transferGoods - invoke function to move goods from one owner company to another
+func (t *BienChaincode) transferGoods(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+
+	/*		0
+		json
+		{
+			"gdsid": "COMPANA10000010",
+			"fromCompany": "company2",
+			"toCompany": "company1",
+			"quantity": 3			// optional, the whole holding of fromCompany moves without it
+		}
+	*/
+
+	var tr Transaction
+	err := json.Unmarshal([]byte(args[0]), &tr)
+	if err != nil {
+		fmt.Println(err)
+		return nil, argError("transaction", "Invalid goods transfer")
+	}
+	// only an owner gives its goods away
+	caller, err := callerIdentity(stub)
+	if err != nil {
+		return nil, err
+	}
+	err = requireCompany(caller, tr.FromCompany)
+	if err != nil {
+		return nil, err
+	}
+
+	goods, err := prepareTransfer(stub, &tr)
+	if err != nil {
+		return nil, err
+	}
+	err = commitTransfer(stub, goods, &tr)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("Transferred goods %s from %s to %s\n", tr.GDSID, tr.FromCompany, tr.ToCompany)
+	return nil, nil
+}
+
+// prepareTransfer - validates a transaction and returns its goods with the ownership already moved, nothing is written yet
+func prepareTransfer(stub shim.ChaincodeStubInterface, tr *Transaction) (Goods, error) {
+	var goods Goods
+	if tr.GDSID == "" || tr.FromCompany == "" || tr.ToCompany == "" {
+		return goods, newError(CodeInvalidArgument, "Transfer needs gdsid, fromCompany and toCompany")
+	}
+	if tr.FromCompany == tr.ToCompany {
+		return goods, newError(CodeInvalidArgument, "Cannot transfer goods to the same company")
+	}
+	if tr.Quantity < 0 {
+		return goods, newError(CodeInvalidArgument, "Transfer quantity must be positive")
+	}
+	err := checkCompanies(stub, tr.FromCompany, tr.ToCompany)
+	if err != nil {
+		return goods, err
+	}
+
+	fmt.Println("Getting State on goods " + tr.GDSID)
+	goods, err = getGoods(stub, tr.GDSID)
+	if err != nil {
+		return goods, err
+	}
+	if isTerminalState(currentState(goods)) {
+		return goods, newError(CodeGoodsInactive, "Goods "+tr.GDSID+" is "+currentState(goods)+" and cannot be transferred")
+	}
+
+	// record the quantity actually moved, it is the whole holding when none was given
+	tr.Quantity, err = moveQuantity(&goods, tr.FromCompany, tr.ToCompany, tr.Quantity)
+	if err != nil {
+		fmt.Println(err)
+		return goods, err
+	}
+	return goods, nil
+}
+
+// commitTransfer - writes the goods moved by prepareTransfer, its transfer record and the owners' asset lists,
+// then emits GoodsSold for purchases and GoodsTransferred for everything else
+func commitTransfer(stub shim.ChaincodeStubInterface, goods Goods, tr *Transaction) error {
+	old, err := getGoods(stub, goods.GDSID)
+	if err != nil {
+		return err
+	}
+	err = putGoods(stub, goods, "transfer")
+	if err != nil {
+		return err
+	}
+	err = recordTransfer(stub, tr)
+	if err != nil {
+		return err
+	}
+	err = syncAssets(stub, goods, tr.FromCompany, tr.ToCompany)
+	if err != nil {
+		return err
+	}
+
+	event := GoodsEvent{GDSID: goods.GDSID, Old: old.Owners, New: goods.Owners, Transfer: tr}
+	if tr.Price > 0 {
+		event.Settlement = &Settlement{Buyer: tr.ToCompany, Seller: tr.FromCompany, Amount: tr.Price * float64(tr.Quantity)}
+		return emitGoodsEvent(stub, EventGoodsSold, event)
+	}
+	return emitGoodsEvent(stub, EventGoodsTransferred, event)
+}
+
+// moveQuantity - moves quantity units of goods from one owner to another, quantity 0 moves the whole holding
+func moveQuantity(goods *Goods, from string, to string, quantity int) (int, error) {
+	fromIdx := -1
+	toIdx := -1
+	for i, owner := range goods.Owners {
+		if owner.Company == from {
+			fromIdx = i
+		}
+		if owner.Company == to {
+			toIdx = i
+		}
+	}
+	if fromIdx < 0 || goods.Owners[fromIdx].Quantity <= 0 {
+		fmt.Println("From company is not an owner of " + goods.GDSID)
+		return 0, newError(CodeNotOwner, from+" does not own goods "+goods.GDSID)
+	}
+	held := goods.Owners[fromIdx].Quantity
+	if quantity == 0 {
+		quantity = held
+	}
+	if quantity > held {
+		return 0, newError(CodeInsufficientQuantity, from+" owns "+strconv.Itoa(held)+" of goods "+goods.GDSID+
+			", cannot transfer "+strconv.Itoa(quantity))
+	}
+
+	goods.Owners[fromIdx].Quantity -= quantity
+	if toIdx < 0 {
+		goods.Owners = append(goods.Owners, Owner{Company: to, Quantity: quantity})
+	} else {
+		goods.Owners[toIdx].Quantity += quantity
+	}
+
+	// Owners left without any quantity are dropped from the list
+	var owners []Owner
+	for _, owner := range goods.Owners {
+		if owner.Quantity > 0 {
+			owners = append(owners, owner)
+		}
+	}
+	goods.Owners = owners
+
+	if totalOwned(*goods) > goods.Quantity {
+		return 0, newError(CodeInsufficientQuantity, "Owner quantities of goods "+goods.GDSID+" exceed the issued quantity")
+	}
+	return quantity, nil
+}
+
+// totalOwned - sum of the quantities held by all owners
+func totalOwned(goods Goods) int {
+	total := 0
+	for _, owner := range goods.Owners {
+		total += owner.Quantity
+	}
+	return total
+}
+
+// recordTransfer - stores the transaction under the next sequence number of its goods
+func recordTransfer(stub shim.ChaincodeStubInterface, tr *Transaction) error {
+	seqKey := transferPrefix + tr.GDSID
+	seqBytes, err := stub.GetState(seqKey)
+	if err != nil {
+		return newError(CodeLedger, "Error retrieving transfer sequence for "+tr.GDSID)
+	}
+	seq := 0
+	if seqBytes != nil {
+		seq, err = strconv.Atoi(string(seqBytes))
+		if err != nil {
+			return newError(CodeCorruptRecord, "Corrupt transfer sequence for "+tr.GDSID)
+		}
+	}
+	seq++
+	tr.Sequence = seq
+	tr.SchemaVersion = schemaVersions[kindTransaction]
+
+	trBytes, err := json.Marshal(tr)
+	if err != nil {
+		fmt.Println("Error marshalling transaction")
+		return newError(CodeInternal, "Error marshalling transaction")
+	}
+	err = stub.PutState(seqKey+":"+strconv.Itoa(seq), trBytes)
+	if err != nil {
+		return newError(CodeLedger, "Error writing transaction for "+tr.GDSID)
+	}
+	return stub.PutState(seqKey, []byte(strconv.Itoa(seq)))
+}
+
+// getGoods - reads the goods record stored for a GDSID
+func getGoods(stub shim.ChaincodeStubInterface, gdsid string) (Goods, error) {
+	var goods Goods
+
+	issuer, err := goodsIssuer(stub, gdsid)
+	if err != nil {
+		return goods, err
+	}
+	if issuer == "" {
+		// records written before composite keys are read where they are until migrate_goods moves them
+		legacy, err := legacyGoods(stub, gdsid)
+		if err != nil {
+			return goods, err
+		}
+		if legacy == nil {
+			return goods, newError(CodeGoodsNotFound, "Goods "+gdsid+" does not exist")
+		}
+		return *legacy, nil
+	}
+	key, err := goodsKey(issuer, gdsid)
+	if err != nil {
+		return goods, err
+	}
+
+	goodsBytes, err := stub.GetState(key)
+	if err != nil {
+		fmt.Println("Error retrieving goods " + gdsid)
+		return goods, newError(CodeLedger, "Error retrieving goods "+gdsid)
+	}
+	if goodsBytes == nil {
+		return goods, newError(CodeGoodsNotFound, "Goods "+gdsid+" does not exist")
+	}
+	err = unmarshalRecord(kindGoods, goodsBytes, &goods)
+	if err != nil {
+		fmt.Println("Error unmarshalling goods " + gdsid)
+		return goods, newError(CodeCorruptRecord, "Error unmarshalling goods "+gdsid)
+	}
+	return goods, nil
+}
+
+// goodsExists - true when a goods record was issued under the GDSID
+func goodsExists(stub shim.ChaincodeStubInterface, gdsid string) (bool, error) {
+	issuer, err := goodsIssuer(stub, gdsid)
+	if err != nil {
+		return false, err
+	}
+	if issuer != "" {
+		return true, nil
+	}
+	legacy, err := legacyGoods(stub, gdsid)
+	if err != nil {
+		return false, err
+	}
+	return legacy != nil, nil
+}
+
+// legacyGoods - the goods record still stored under goods:<id>, nil when there is none
+func legacyGoods(stub shim.ChaincodeStubInterface, gdsid string) (*Goods, error) {
+	goodsBytes, err := stub.GetState(goodsPrefix + gdsid)
+	if err != nil {
+		return nil, newError(CodeLedger, "Error retrieving goods "+gdsid)
+	}
+	if goodsBytes == nil {
+		return nil, nil
+	}
+	var goods Goods
+	err = unmarshalRecord(kindGoods, goodsBytes, &goods)
+	if err != nil {
+		return nil, newError(CodeCorruptRecord, "Error unmarshalling goods "+gdsid)
+	}
+	if goods.GDSID == "" {
+		goods.GDSID = gdsid
+	}
+	return &goods, nil
+}
+
+// goodsIssuer - looks up the issuer part of the goods composite key, "" for unknown goods
+func goodsIssuer(stub shim.ChaincodeStubInterface, gdsid string) (string, error) {
+	locatorKey, err := createCompositeKey(gdsidObjectType, []string{gdsid})
+	if err != nil {
+		return "", err
+	}
+	issuerBytes, err := stub.GetState(locatorKey)
+	if err != nil {
+		fmt.Println("Error retrieving goods " + gdsid)
+		return "", newError(CodeLedger, "Error retrieving goods "+gdsid)
+	}
+	return string(issuerBytes), nil
+}
+
+// putGoods - writes the goods record under its composite key, together with the GDSID locator,
+// and appends the change to the goods history
+func putGoods(stub shim.ChaincodeStubInterface, goods Goods, action string) error {
+	key, err := goodsKey(goods.Issuer, goods.GDSID)
+	if err != nil {
+		return err
+	}
+	locatorKey, err := createCompositeKey(gdsidObjectType, []string{goods.GDSID})
+	if err != nil {
+		return err
+	}
+
+	oldBytes, err := stub.GetState(key)
+	if err != nil {
+		fmt.Println("Error retrieving goods " + goods.GDSID)
+		return newError(CodeLedger, "Error retrieving goods "+goods.GDSID)
+	}
+	var old *Goods
+	if oldBytes != nil {
+		old = &Goods{}
+		err = unmarshalRecord(kindGoods, oldBytes, old)
+		if err != nil {
+			return newError(CodeCorruptRecord, "Error unmarshalling goods "+goods.GDSID)
+		}
+	} else {
+		// a record read from its goods:<id> key moves to the composite key with its first write
+		old, err = legacyGoods(stub, goods.GDSID)
+		if err != nil {
+			return err
+		}
+		if old != nil {
+			err = stub.DelState(goodsPrefix + goods.GDSID)
+			if err != nil {
+				return newError(CodeLedger, "Error removing legacy goods key of "+goods.GDSID)
+			}
+		}
+	}
+
+	goods.SchemaVersion = schemaVersions[kindGoods]
+	goodsBytes, err := json.Marshal(&goods)
+	if err != nil {
+		fmt.Println("Error marshalling goods")
+		return newError(CodeInternal, "Error marshalling goods "+goods.GDSID)
+	}
+	err = stub.PutState(key, goodsBytes)
+	if err != nil {
+		fmt.Println("Error writing goods")
+		return newError(CodeLedger, "Error writing goods "+goods.GDSID)
+	}
+	err = stub.PutState(locatorKey, []byte(goods.Issuer))
+	if err != nil {
+		fmt.Println("Error writing goods locator")
+		return newError(CodeLedger, "Error writing goods "+goods.GDSID)
+	}
+	return recordGoodsChange(stub, action, old, &goods)
+}
+
+// change_state - invoke function to move goods to the next state of its lifecycle
+func (t *BienChaincode) change_state(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	//   0       1         2
+	// GDSID  "state"  "company", ordering company, only with ordered
+
+	fmt.Println("- start change state -")
+	goods, err := getLegacyGoods(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := checkTransition(goods, args[1])
+	if err != nil {
+		fmt.Println(err)
+		return nil, err
+	}
+	caller, err := callerIdentity(stub)
+	if err != nil {
+		return nil, err
+	}
+	err = checkStateChange(caller, goods, state)
+	if err != nil {
+		return nil, err
+	}
+	orderedBy := ""
+	if len(args) == 3 {
+		orderedBy = args[2]
+	}
+	if state == StateOrdered {
+		if orderedBy == "" {
+			return nil, argError("company", "Name the company ordering goods "+goods.GDSID)
+		}
+		err = checkCompanies(stub, orderedBy)
+		if err != nil {
+			return nil, err
+		}
+		goods.OrderedBy = orderedBy
+	} else if orderedBy != "" {
+		return nil, argError("company", "Only goods moving to ordered take the ordering company")
+	}
+	if state == StateListed {
+		goods.OrderedBy = ""
+	}
+	old := currentState(goods)
+	logger.Infof("change_state %s: %s -> %s", goods.GDSID, old, state)
+	goods.State = state
+
+	err = putGoods(stub, goods, "change_state")
+	if err != nil {
+		return nil, err
+	}
+	err = emitGoodsEvent(stub, EventGoodsStateChanged, GoodsEvent{GDSID: goods.GDSID, Old: old, New: state})
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Println("- end change state-")
+	return nil, nil
+}
+
+// set_price - invoke function by which the issuer reprices its goods
+func (t *BienChaincode) set_price(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	//   0       1
+	// GDSID  price
+	price, err := strconv.ParseFloat(args[1], 64)
+	if err != nil || price <= 0 {
+		return nil, argError("price", "Price must be a positive number")
+	}
+
+	goods, err := getGoods(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+	caller, err := callerIdentity(stub)
+	if err != nil {
+		return nil, err
+	}
+	err = requireCompany(caller, goods.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	err = checkCompanies(stub, goods.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	if isTerminalState(currentState(goods)) {
+		return nil, newError(CodeGoodsInactive, "Goods "+goods.GDSID+" is "+currentState(goods)+" and cannot be repriced")
+	}
+
+	old := goods.Price
+	goods.Price = price
+	err = putGoods(stub, goods, "set_price")
+	if err != nil {
+		return nil, err
+	}
+	return nil, emitGoodsEvent(stub, EventGoodsPriceChanged, GoodsEvent{GDSID: goods.GDSID, Old: old, New: price})
+}
+
+func GetAllgoods(stub shim.ChaincodeStubInterface) ([]Goods, error){
+	return rangeGoods(stub)
+}
+
+// GetGoodsByIssuer - all goods issued by one company
+func GetGoodsByIssuer(stub shim.ChaincodeStubInterface, issuer string) ([]Goods, error){
+	return rangeGoods(stub, issuer)
+}
+
+// rangeGoods - scans the goods composite keys starting with the given attributes
+func rangeGoods(stub shim.ChaincodeStubInterface, attributes ...string) ([]Goods, error){
+	
+	allGDs := []Goods{}
+	
+	startKey, endKey, err := compositeKeyRange(goodsObjectType, attributes)
+	if err != nil {
+		return nil, err
+	}
+	iter, err := stub.RangeQueryState(startKey, endKey)
+	if err != nil {
+		fmt.Println("Error scanning goods")
+		return nil, newError(CodeLedger, "Error scanning goods")
+	}
+	defer iter.Close()
+
+	for iter.HasNext() {
+		key, gdBytes, err := iter.Next()
+		if err != nil {
+			fmt.Println("Error scanning goods")
+			return nil, newError(CodeLedger, "Error scanning goods")
+		}
+
+		var gd Goods
+		err = unmarshalRecord(kindGoods, gdBytes, &gd)
+		if err != nil {
+			_, attributes, _ := splitCompositeKey(key)
+			fmt.Println("Error retrieving gd ", attributes)
+			return nil, newError(CodeCorruptRecord, "Error retrieving gd "+attributes[len(attributes)-1])
+		}
+		
+		fmt.Println("Appending Goods" + gd.GDSID)
+		allGDs = append(allGDs, gd)
+	}	
+
+	// records not yet moved off their goods:<id> keys
+	legacy, err := scanRaw(stub, goodsPrefix, goodsPrefix+maxUnicodeRune)
+	if err != nil {
+		return nil, err
+	}
+	for _, kv := range legacy {
+		gd, err := legacyGoods(stub, strings.TrimPrefix(kv.key, goodsPrefix))
+		if err != nil {
+			return nil, err
+		}
+		if len(attributes) > 0 && gd.Issuer != attributes[0] {
+			continue
+		}
+		allGDs = append(allGDs, *gd)
+	}
+	
+	return allGDs, nil
+}
+func GetGD(gdid string, stub shim.ChaincodeStubInterface) (Goods, error){
+	var gd Goods
+	// any registered identifier leads to the goods, ids of no scheme are looked up as they are
+	gdsid, err := resolveGDSID(stub, gdid)
+	if err != nil {
+		fmt.Println("Error resolving gd " + gdid)
+		return gd, err
+	}
+
+	gd, err = getGoods(stub, gdsid)
+	if err != nil {
+		fmt.Println("Error retrieving gd " + gdid)
+		return gd, err
+	}
+		
+	return gd, nil
+}
+var seventhDigit = map[int]string{
+	1:  "A",
+	2:  "B",
+	3:  "C",
+	4:  "D",
+	5:  "E",
+	6:  "F",
+	7:  "G",
+	8:  "H",
+	9:  "J",
+	10: "K",
+	11: "L",
+	12: "M",
+	13: "N",
+	14: "P",
+	15: "Q",
+	16: "R",
+	17: "S",
+	18: "T",
+	19: "U",
+	20: "V",
+	21: "W",
+	22: "X",
+	23: "Y",
+	24: "Z",
+}
+
+var eigthDigit = map[int]string{
+	1:  "1",
+	2:  "2",
+	3:  "3",
+	4:  "4",
+	5:  "5",
+	6:  "6",
+	7:  "7",
+	8:  "8",
+	9:  "9",
+	10: "A",
+	11: "B",
+	12: "C",
+	13: "D",
+	14: "E",
+	15: "F",
+	16: "G",
+	17: "H",
+	18: "J",
+	19: "K",
+	20: "L",
+	21: "M",
+	22: "N",
+	23: "P",
+	24: "Q",
+	25: "R",
+	26: "S",
+	27: "T",
+	28: "U",
+	29: "V",
+	30: "W",
+	31: "X",
+}
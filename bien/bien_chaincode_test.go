@@ -0,0 +1,423 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		 http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bien
+
+import (
+	"reflect"
+	"testing"
+)
+
+const (
+	// goods stored under goods:legacy1 before composite keys
+	legacyGoodsRecord = `{"goodsId":"legacy1","name":"stool","price":4,"postage":1,"owner":[{"company":"company2","quantity":2}],` +
+		`"issuer":"company2","state":"listed","quantity":2,"schemaVersion":2}`
+	// goods orphan1 whose issuer was never registered
+	orphanGoodsRecord = `{"goodsId":"orphan1","name":"crate","price":4,"owner":[{"company":"ghost","quantity":1}],` +
+		`"issuer":"ghost","state":"new","quantity":1,"schemaVersion":2}`
+)
+
+func putLegacyGoods(l *testLedger) {
+	l.put(goodsPrefix+"legacy1", legacyGoodsRecord)
+}
+
+func putOrphanGoods(l *testLedger) {
+	l.put(compositeKey(goodsObjectType, "ghost", "orphan1"), orphanGoodsRecord)
+	l.put(compositeKey(gdsidObjectType, "orphan1"), "ghost")
+}
+
+func cancelGoods(l *testLedger) {
+	l.walk(StateCancelled)
+}
+
+func suspend(company string) func(l *testLedger) {
+	return func(l *testLedger) {
+		l.must(asAdmin, "suspend_company", company)
+	}
+}
+
+// useGTIN13 - company2 numbers its goods with GTIN-13s under the company prefix
+func useGTIN13(prefix string) func(l *testLedger) {
+	return func(l *testLedger) {
+		l.must(asCarol, "set_id_scheme", `{"issuer":"company2","scheme":"gtin13","companyPrefix":"`+prefix+`"}`)
+	}
+}
+
+// takeFirstGTIN - company2 numbers by GTIN-13 and the first one is already registered elsewhere
+func takeFirstGTIN(l *testLedger) {
+	useGTIN13("400638")(l)
+	gtin, _ := idSchemes[SchemeGTIN13].Generate(IDSchemeSettings{Scheme: SchemeGTIN13, CompanyPrefix: "400638"}, 1)
+	l.put(compositeKey(goodsIDObjectType, SchemeGTIN13, gtin), "OTHER")
+}
+
+var goodsCases = register([]funcCase{
+	{name: "issues goods owned by the issuer", fn: "add_goods", as: asCarol,
+		args: []string{`{"name":"table","price":40,"issuer":"company2","quantity":4}`},
+		event: EventGoodsIssued,
+		check: func(l *testLedger, result []byte) {
+			info, err := parseGDSID(string(result))
+			if err != nil {
+				l.t.Fatalf("add_goods returned %s: %v", result, err)
+			}
+			if info.IssuerPrefix != "COMPAN" || info.DateCode != "LG" || info.Sequence != 2 {
+				l.t.Errorf("GDSID parts are %+v, want COMPAN, LG and 2", info)
+			}
+			goods := l.goods(string(result))
+			if goods.State != StateNew || goods.Quantity != 4 || goods.Issuer != "company2" {
+				l.t.Errorf("issued %+v", goods)
+			}
+			checkOwners(l, string(result), owners(Owner{"company2", 4}))
+			if assets := l.account("company2").AssetsIds; len(assets) != 2 || assets[1] != string(result) {
+				l.t.Errorf("assets of company2 are %v", assets)
+			}
+			if event := l.lastEvent(); event.GDSID != string(result) || event.Actor != "carol" || event.Company != "company2" ||
+				event.Timestamp != testTime.UnixNano()/1e6 {
+				l.t.Errorf("GoodsIssued event is %+v", event)
+			}
+		}},
+	{name: "takes the five arguments of the bien variants", fn: "add_goods", as: asCarol,
+		args: []string{"lamp", "company2", "new", "20", "2"},
+		event: EventGoodsIssued,
+		check: func(l *testLedger, result []byte) {
+			goods := l.goods(string(result))
+			if goods.Name != "lamp" || goods.Price != 20 || goods.Postage != 2 || goods.Quantity != 1 {
+				l.t.Errorf("issued %+v", goods)
+			}
+		}},
+	{name: "gives the goods a GTIN of the issuer's scheme", fn: "add_goods", as: asCarol,
+		setup: useGTIN13("400638"),
+		args: []string{`{"name":"table","issuer":"company2"}`},
+		event: EventGoodsIssued,
+		check: func(l *testLedger, result []byte) {
+			gtin, _ := idSchemes[SchemeGTIN13].Generate(IDSchemeSettings{Scheme: SchemeGTIN13, CompanyPrefix: "400638"}, 1)
+			if got := l.goods(string(result)).Identifiers[SchemeGTIN13]; got != gtin {
+				l.t.Errorf("GTIN is %s, want %s", got, gtin)
+			}
+			if got := l.goods(gtin).GDSID; got != string(result) {
+				l.t.Errorf("GTIN %s finds %s, want %s", gtin, got, result)
+			}
+		}},
+	{name: "goes on with GDSIDs only when the scheme runs out", fn: "add_goods", as: asCarol,
+		setup: func(l *testLedger) {
+			useGTIN13("40063810000")(l)
+			l.put(compositeKey(gdsidSeqObjectType, "gtin13:40063810000"), "9")
+		},
+		args: []string{`{"name":"table","issuer":"company2"}`},
+		event: EventGoodsIssued,
+		check: func(l *testLedger, result []byte) {
+			if ids := l.goods(string(result)).Identifiers; len(ids) != 0 {
+				l.t.Errorf("goods got identifiers %v", ids)
+			}
+			if settings := l.idScheme("company2"); settings.Scheme != SchemeGDSID || settings.Reason == "" {
+				l.t.Errorf("id scheme is %+v, want gdsid with a reason", settings)
+			}
+		}},
+	{name: "only issues goods in state new", fn: "add_goods", as: asCarol,
+		args: []string{`{"name":"table","issuer":"company2","state":"shipped"}`},
+		code: CodeInvalidState},
+	{name: "needs an issuer", fn: "add_goods", as: asCarol,
+		args: []string{`{"name":"table","quantity":2}`},
+		code: CodeInvalidArgument},
+	{name: "does not issue goods at a negative price", fn: "add_goods", as: asCarol,
+		args: []string{`{"name":"table","issuer":"company2","price":-5}`},
+		code: CodeInvalidArgument},
+	{name: "does not issue goods with negative postage", fn: "add_goods", as: asCarol,
+		args: []string{"table", "company2", "new", "5", "-1"},
+		code: CodeInvalidArgument},
+	{name: "needs a registered issuer", fn: "add_goods", as: asAdmin,
+		args: []string{`{"name":"table","issuer":"ghost"}`},
+		code: CodeCompanyNotFound},
+	{name: "needs an active issuer", fn: "add_goods", as: asAdmin,
+		args: []string{`{"name":"table","issuer":"company3"}`},
+		code: CodeCompanySuspended},
+	{name: "never issues a GDSID twice", fn: "add_goods", as: asCarol,
+		setup: func(l *testLedger) {
+			delete(l.stub.State, compositeKey(gdsidSeqObjectType, "COMPAN"))
+		},
+		args: []string{`{"name":"table","issuer":"company2"}`},
+		code: CodeGoodsExists},
+	{name: "fails when the issuer prefix has no GDSID left", fn: "add_goods", as: asCarol,
+		setup: func(l *testLedger) {
+			l.put(compositeKey(gdsidSeqObjectType, "COMPAN"), "999999")
+		},
+		args: []string{`{"name":"table","issuer":"company2"}`},
+		code: CodeIdentifiersExhausted},
+	{name: "does not reuse a GTIN registered to other goods", fn: "add_goods", as: asCarol,
+		setup: takeFirstGTIN,
+		args: []string{`{"name":"table","issuer":"company2"}`},
+		code: CodeIdentifierTaken},
+	{name: "numbers bien variant goods by the scheme too", fn: "add_goods", as: asCarol,
+		setup: takeFirstGTIN,
+		args: []string{"lamp", "company2", "new", "20", "2"},
+		code: CodeIdentifierTaken},
+
+	{name: "moves units to another company", fn: "transfer_goods", as: asCarol,
+		args: []string{`{"gdsid":"{gdsid}","fromCompany":"company2","toCompany":"company1","quantity":3}`},
+		event: EventGoodsTransferred,
+		check: func(l *testLedger, result []byte) {
+			checkOwners(l, l.vars["gdsid"], owners(Owner{"company2", 7}, Owner{"company1", 3}))
+			if assets := l.account("company1").AssetsIds; len(assets) != 1 || assets[0] != l.vars["gdsid"] {
+				l.t.Errorf("assets of company1 are %v", assets)
+			}
+			if event := l.lastEvent(); event.Transfer == nil || event.Transfer.Sequence != 1 || event.Settlement != nil {
+				l.t.Errorf("GoodsTransferred event is %+v", event)
+			}
+			var tr Transaction
+			l.decode(l.stub.State[transferPrefix+l.vars["gdsid"]+":1"], &tr)
+			if tr.FromCompany != "company2" || tr.ToCompany != "company1" || tr.Quantity != 3 {
+				l.t.Errorf("transfer record is %+v", tr)
+			}
+		}},
+	{name: "moves the whole holding without a quantity", fn: "transfer_goods", as: asCarol,
+		args: []string{`{"gdsid":"{gdsid}","fromCompany":"company2","toCompany":"company1"}`},
+		event: EventGoodsTransferred,
+		check: func(l *testLedger, result []byte) {
+			checkOwners(l, l.vars["gdsid"], owners(Owner{"company1", 10}))
+			if assets := l.account("company2").AssetsIds; len(assets) != 0 {
+				l.t.Errorf("assets of company2 are %v", assets)
+			}
+		}},
+	{name: "moves goods still stored under goods: keys", fn: "transfer_goods", as: asCarol,
+		setup: putLegacyGoods,
+		args: []string{`{"gdsid":"legacy1","fromCompany":"company2","toCompany":"company1","quantity":1}`},
+		event: EventGoodsTransferred,
+		check: func(l *testLedger, result []byte) {
+			checkOwners(l, "legacy1", owners(Owner{"company2", 1}, Owner{"company1", 1}))
+			if l.stub.State[goodsPrefix+"legacy1"] != nil {
+				l.t.Error("goods:legacy1 is still there")
+			}
+		}},
+	{name: "needs existing goods", fn: "transfer_goods", as: asCarol,
+		args: []string{`{"gdsid":"nothere","fromCompany":"company2","toCompany":"company1"}`},
+		code: CodeGoodsNotFound},
+	{name: "does not move cancelled goods", fn: "transfer_goods", as: asCarol,
+		setup: cancelGoods,
+		args: []string{`{"gdsid":"{gdsid}","fromCompany":"company2","toCompany":"company1"}`},
+		code: CodeGoodsInactive},
+	{name: "needs an owner to give the goods", fn: "transfer_goods", as: asAlice,
+		args: []string{`{"gdsid":"{gdsid}","fromCompany":"company1","toCompany":"company2"}`},
+		code: CodeNotOwner},
+	{name: "does not move more than the owner holds", fn: "transfer_goods", as: asCarol,
+		args: []string{`{"gdsid":"{gdsid}","fromCompany":"company2","toCompany":"company1","quantity":11}`},
+		code: CodeInsufficientQuantity},
+	{name: "needs a registered receiver", fn: "transfer_goods", as: asCarol,
+		args: []string{`{"gdsid":"{gdsid}","fromCompany":"company2","toCompany":"ghost"}`},
+		code: CodeCompanyNotFound},
+	{name: "needs an active receiver", fn: "transfer_goods", as: asCarol,
+		args: []string{`{"gdsid":"{gdsid}","fromCompany":"company2","toCompany":"company3"}`},
+		code: CodeCompanySuspended},
+	{name: "needs two companies", fn: "transfer_goods", as: asCarol,
+		args: []string{`{"gdsid":"{gdsid}","fromCompany":"company2","toCompany":"company2"}`},
+		code: CodeInvalidArgument},
+
+	{name: "moves goods to the next state", fn: "change_state", as: asCarol,
+		args: []string{"{gdsid}", "Listed"},
+		event: EventGoodsStateChanged,
+		check: func(l *testLedger, result []byte) {
+			checkState(l, l.vars["gdsid"], StateListed)
+			if event := l.lastEvent(); event.Old != StateNew || event.New != StateListed {
+				l.t.Errorf("GoodsStateChanged event is %+v", event)
+			}
+		}},
+	{name: "needs existing goods", fn: "change_state", as: asCarol,
+		args: []string{"nothere", "listed"},
+		code: CodeGoodsNotFound},
+	{name: "needs a known state", fn: "change_state", as: asCarol,
+		args: []string{"{gdsid}", "bogus"},
+		code: CodeInvalidState},
+	{name: "does not skip states", fn: "change_state", as: asCarol,
+		args: []string{"{gdsid}", "shipped"},
+		code: CodeInvalidStateTransition},
+	{name: "lists goods with a price only", fn: "change_state", as: asCarol,
+		setup: func(l *testLedger) {
+			l.vars["free"] = string(l.must(asCarol, "add_goods", `{"name":"leaflet","issuer":"company2"}`))
+		},
+		args: []string{"{free}", "listed"},
+		code: CodeStatePreconditionFailed},
+	{name: "lets an owner move its goods", fn: "change_state", as: asAlice,
+		setup: func(l *testLedger) {
+			l.must(asCarol, "transfer_goods", `{"gdsid":"{gdsid}","fromCompany":"company2","toCompany":"company1"}`)
+		},
+		args: []string{"{gdsid}", "listed"},
+		event: EventGoodsStateChanged},
+	{name: "is for the issuer and the owners", fn: "change_state", as: asDave,
+		args: []string{"{gdsid}", "listed"},
+		code: CodeForbidden},
+
+	{name: "returns every goods record", fn: "GetAllgoods", as: asAnonymous,
+		setup: func(l *testLedger) {
+			l.must(asAlice, "add_goods", `{"name":"desk","price":90,"issuer":"company1"}`)
+		},
+		check: func(l *testLedger, result []byte) {
+			var all []Goods
+			l.decode(result, &all)
+			if len(all) != 2 || all[0].Issuer != "company1" || all[1].GDSID != l.vars["gdsid"] {
+				l.t.Errorf("GetAllgoods returned %s", result)
+			}
+		}},
+	{name: "returns goods still stored under goods: keys", fn: "GetAllgoods", as: asAnonymous,
+		setup: putLegacyGoods,
+		check: func(l *testLedger, result []byte) {
+			var all []Goods
+			l.decode(result, &all)
+			if len(all) != 2 || all[0].GDSID != l.vars["gdsid"] || all[1].GDSID != "legacy1" {
+				l.t.Errorf("GetAllgoods returned %s", result)
+			}
+		}},
+
+	{name: "finds goods by GDSID", fn: "GetGD", as: asAnonymous,
+		args: []string{"{gdsid}"},
+		check: func(l *testLedger, result []byte) {
+			var goods Goods
+			l.decode(result, &goods)
+			if goods.GDSID != l.vars["gdsid"] || goods.Name != "chair" || goods.Price != 12.5 || goods.Postage != 3 {
+				l.t.Errorf("GetGD returned %+v", goods)
+			}
+		}},
+	{name: "finds goods by a registered GTIN", fn: "GetGD", as: asAnonymous,
+		setup: func(l *testLedger) {
+			l.must(asCarol, "register_goods_id", "{gdsid}", SchemeGTIN13, "4006381333931")
+		},
+		args: []string{"4006381333931"},
+		check: func(l *testLedger, result []byte) {
+			var goods Goods
+			l.decode(result, &goods)
+			if goods.GDSID != l.vars["gdsid"] {
+				l.t.Errorf("GetGD returned %+v", goods)
+			}
+		}},
+	{name: "reads goods still stored under goods: keys", fn: "GetGD", as: asAnonymous,
+		setup: putLegacyGoods,
+		args: []string{"legacy1"},
+		check: func(l *testLedger, result []byte) {
+			var goods Goods
+			l.decode(result, &goods)
+			if goods.GDSID != "legacy1" || goods.Quantity != 2 {
+				l.t.Errorf("GetGD returned %+v", goods)
+			}
+		}},
+	{name: "fails on unknown goods", fn: "GetGD", as: asAnonymous,
+		args: []string{"nothere"},
+		code: CodeGoodsNotFound},
+	{name: "fails on an unregistered GTIN", fn: "GetGD", as: asAnonymous,
+		args: []string{"4006381333931"},
+		code: CodeGoodsNotFound},
+	{name: "turns away GDSIDs with a wrong check digit", fn: "GetGD", as: asAnonymous,
+		args: []string{"COMPANLG0000010"},
+		code: CodeInvalidIdentifier},
+
+	{name: "reprices goods", fn: "set_price", as: asCarol,
+		args: []string{"{gdsid}", "15"},
+		event: EventGoodsPriceChanged,
+		check: func(l *testLedger, result []byte) {
+			if goods := l.goods(l.vars["gdsid"]); goods.Price != 15 {
+				l.t.Errorf("price is %v, want 15", goods.Price)
+			}
+		}},
+	{name: "needs existing goods", fn: "set_price", as: asCarol,
+		args: []string{"nothere", "15"},
+		code: CodeGoodsNotFound},
+	{name: "does not reprice cancelled goods", fn: "set_price", as: asCarol,
+		setup: cancelGoods,
+		args: []string{"{gdsid}", "15"},
+		code: CodeGoodsInactive},
+	{name: "needs a registered issuer", fn: "set_price", as: asAdmin,
+		setup: putOrphanGoods,
+		args: []string{"orphan1", "15"},
+		code: CodeCompanyNotFound},
+	{name: "needs an active issuer", fn: "set_price", as: asAdmin,
+		setup: suspend("company2"),
+		args: []string{"{gdsid}", "15"},
+		code: CodeCompanySuspended},
+	{name: "is for the issuer", fn: "set_price", as: asAlice,
+		args: []string{"{gdsid}", "15"},
+		code: CodeForbidden},
+	{name: "needs a positive price", fn: "set_price", as: asCarol,
+		args: []string{"{gdsid}", "-1"},
+		code: CodeInvalidArgument},
+
+	{name: "resets abc and the order book", fn: "init", as: asAdmin,
+		setup: openSell,
+		args: []string{"5"},
+		check: func(l *testLedger, result []byte) {
+			if value := string(l.must(asAnonymous, "read", "abc")); value != "5" {
+				l.t.Errorf("abc is %q, want 5", value)
+			}
+			if trades := openTrades(l); len(trades) != 0 {
+				l.t.Errorf("open trades are %+v", trades)
+			}
+		}},
+	{name: "is for admins", fn: "init", as: asAlice,
+		args: []string{"5"},
+		code: CodeForbidden},
+})
+
+func TestGoodsFunctions(t *testing.T) {
+	runCases(t, goodsCases)
+}
+
+// TestGetAllgoodsEmpty - an empty ledger has a list of no goods, not null
+func TestGetAllgoodsEmpty(t *testing.T) {
+	l := newLedger(t)
+	if result := string(l.must(asAnonymous, "GetAllgoods")); result != "[]" {
+		t.Errorf("GetAllgoods returned %s, want []", result)
+	}
+}
+
+// TestQueryOldCalls - queries of no known function read the key named by the first argument
+func TestQueryOldCalls(t *testing.T) {
+	l := newFixture(t)
+	l.must(asAdmin, "write", "color", "red")
+	result, err := l.stub.MockQuery("query", []string{"color"})
+	if err != nil || string(result) != "red" {
+		t.Errorf("query color returned %s, %v, want red", result, err)
+	}
+}
+
+func TestMoveQuantity(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		from     string
+		to       string
+		quantity int
+		moved    int
+		owners   []Owner
+		fails    bool
+	}{
+		{name: "moves part of a holding", from: "company2", to: "company1", quantity: 3,
+			moved: 3, owners: []Owner{{"company2", 5}, {"company1", 5}}},
+		{name: "adds to the receiver's holding", from: "company1", to: "company2", quantity: 2,
+			moved: 2, owners: []Owner{{"company2", 10}}},
+		{name: "moves the whole holding without a quantity", from: "company2", to: "company3",
+			moved: 8, owners: []Owner{{"company1", 2}, {"company3", 8}}},
+		{name: "needs the sender to own the goods", from: "company3", to: "company1", quantity: 1,
+			fails: true},
+		{name: "does not move more than the sender holds", from: "company1", to: "company2", quantity: 3,
+			fails: true},
+	} {
+		goods := Goods{GDSID: "g1", Quantity: 10, Owners: []Owner{{"company2", 8}, {"company1", 2}}}
+		moved, err := moveQuantity(&goods, c.from, c.to, c.quantity)
+		if c.fails {
+			if err == nil {
+				t.Errorf("%s: moved %d, want an error", c.name, moved)
+			}
+			continue
+		}
+		if err != nil || moved != c.moved || !reflect.DeepEqual(goods.Owners, c.owners) {
+			t.Errorf("%s: moved %d, %v, owners %+v, want %d, %+v", c.name, moved, err, goods.Owners, c.moved, c.owners)
+		}
+		if totalOwned(goods) != goods.Quantity {
+			t.Errorf("%s: owners hold %d of %d", c.name, totalOwned(goods), goods.Quantity)
+		}
+	}
+}
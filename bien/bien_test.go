@@ -0,0 +1,332 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		 http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bien
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/celeC/Bien-Chaincode/mockstub"
+)
+
+// The tests run every chaincode function on mockstub. Each case of the tables starts
+// from a fresh fixture ledger, sends one transaction as one caller and checks the
+// error, the event and whatever check looks at afterwards. A failing invoke must
+// leave the ledger and the event log as they were before it.
+
+// testTime is the time of every transaction, goods issued at it get the date code LG (November 16)
+var testTime = time.Date(2016, time.November, 1, 12, 0, 0, 0, time.UTC)
+
+func TestMain(m *testing.M) {
+	SetClock(FixedClock{Time: testTime})
+	os.Exit(m.Run())
+}
+
+// testCaller is who a transaction is sent as: the common name of its certificate and the
+// company and roles attributes, no name sends it unsigned
+type testCaller struct {
+	name    string
+	company string
+	roles   string
+}
+
+var (
+	asAdmin     = testCaller{name: "deployer"}		//Init makes the deployer admin
+	asAnonymous = testCaller{}
+	asAlice     = testCaller{"alice", "company1", "issuer,buyer"}
+	asBob       = testCaller{"bob", "company1", "buyer"}
+	asCarol     = testCaller{"carol", "company2", "issuer,buyer"}
+	asDave      = testCaller{"dave", "company4", "issuer,buyer"}
+	asSam       = testCaller{"sam", "company1", "shipper"}
+	asSid       = testCaller{"sid", "company2", "shipper"}
+	asAudrey    = testCaller{name: "audrey", roles: "auditor"}
+)
+
+// certificates are generated once per common name, generating keys is slow
+var certificates = map[string][]byte{}
+
+// testLedger is a mockstub ledger with the values the placeholders of case arguments stand for
+type testLedger struct {
+	t    *testing.T
+	stub *mockstub.MockStub
+	vars map[string]string		//{name} in an argument is replaced by vars[name]
+}
+
+// newLedger - a ledger the deployer initialized, nothing else on it
+func newLedger(t *testing.T) *testLedger {
+	l := &testLedger{t: t, stub: mockstub.NewMockStub("bien", new(BienChaincode)), vars: map[string]string{}}
+	l.as(asAdmin)
+	_, err := l.stub.MockInit("init", []string{"1"})
+	if err != nil {
+		t.Fatalf("init: %v", err)
+	}
+	return l
+}
+
+// newFixture - the ledger the cases start from: company1, company2 and company4 are active,
+// company3 is suspended, company1 and company2 have 100 in their accounts and company2 issued
+// 10 chairs at 12.50, their GDSID is {gdsid}
+func newFixture(t *testing.T) *testLedger {
+	l := newLedger(t)
+	for _, company := range []string{
+		`{"id":"company1","legalName":"Company One Ltd","shortCode":"COMP01","roles":["issuer","buyer","shipper"]}`,
+		`{"id":"company2","legalName":"Company Two Ltd","shortCode":"COMPAN","roles":["issuer","buyer","shipper"]}`,
+		`{"id":"company3","legalName":"Company Three Ltd","shortCode":"COMP03","roles":["issuer","buyer"]}`,
+		`{"id":"company4","legalName":"Company Four Ltd","shortCode":"COMP04","roles":["issuer","buyer"]}`,
+	} {
+		l.must(asAdmin, "register_company", company)
+	}
+	l.must(asAdmin, "suspend_company", "company3", "unpaid fees")
+	l.must(asAdmin, "create_account", `{"company":"company1","cashBalance":100}`)
+	l.must(asAdmin, "create_account", `{"company":"company2","cashBalance":100}`)
+	l.vars["gdsid"] = string(l.must(asCarol, "add_goods",
+		`{"name":"chair","price":12.5,"postage":3,"issuer":"company2","quantity":10}`))
+	return l
+}
+
+// as - sends the following transactions as the caller
+func (l *testLedger) as(c testCaller) {
+	if c.name == "" {
+		l.stub.SetCaller(nil, nil)
+		return
+	}
+	cert, ok := certificates[c.name]
+	if !ok {
+		var err error
+		cert, err = mockstub.NewCertificate(c.name)
+		if err != nil {
+			l.t.Fatalf("certificate of %s: %v", c.name, err)
+		}
+		certificates[c.name] = cert
+	}
+	attributes := map[string]string{}
+	if c.company != "" {
+		attributes["company"] = c.company
+	}
+	if c.roles != "" {
+		attributes["roles"] = c.roles
+	}
+	l.stub.SetCaller(cert, attributes)
+}
+
+// call - runs a function as the caller, registered queries as queries and everything else as invokes
+func (l *testLedger) call(c testCaller, function string, args ...string) ([]byte, error) {
+	l.as(c)
+	expanded := make([]string, len(args))
+	for i, arg := range args {
+		for name, value := range l.vars {
+			arg = strings.Replace(arg, "{"+name+"}", value, -1)
+		}
+		expanded[i] = arg
+	}
+	if fn, ok := functions[function]; ok && fn.Kind == KindQuery {
+		return l.stub.MockQuery(function, expanded)
+	}
+	return l.stub.MockInvoke(function, expanded)
+}
+
+// must - call that fails the test on any error
+func (l *testLedger) must(c testCaller, function string, args ...string) []byte {
+	l.t.Helper()
+	result, err := l.call(c, function, args...)
+	if err != nil {
+		l.t.Fatalf("%s %v: %v", function, args, err)
+	}
+	return result
+}
+
+// mustFail - call that fails the test unless it fails with the code
+func (l *testLedger) mustFail(code string, c testCaller, function string, args ...string) {
+	l.t.Helper()
+	_, err := l.call(c, function, args...)
+	if errorCode(err) != code {
+		l.t.Fatalf("%s %v returned %v, want %s", function, args, err, code)
+	}
+}
+
+// errorCode - the code of a chaincode error, "" for nil
+func errorCode(err error) string {
+	if err == nil {
+		return ""
+	}
+	return ParseError(err).Code
+}
+
+// decode - unmarshals a result into v
+func (l *testLedger) decode(result []byte, v interface{}) {
+	l.t.Helper()
+	err := json.Unmarshal(result, v)
+	if err != nil {
+		l.t.Fatalf("decoding %s: %v", result, err)
+	}
+}
+
+// goods - the goods GetGD finds under an id
+func (l *testLedger) goods(id string) Goods {
+	l.t.Helper()
+	var goods Goods
+	l.decode(l.must(asAnonymous, "GetGD", id), &goods)
+	return goods
+}
+
+// account - the account of a company
+func (l *testLedger) account(company string) Account {
+	l.t.Helper()
+	var account Account
+	l.decode(l.must(asAdmin, "get_account", company), &account)
+	return account
+}
+
+// company - a registered company
+func (l *testLedger) company(id string) Company {
+	l.t.Helper()
+	var company Company
+	l.decode(l.must(asAnonymous, "get_company", id), &company)
+	return company
+}
+
+// walk - moves {gdsid} through the states as its issuer, company1 orders the goods and reports them delivered
+func (l *testLedger) walk(states ...string) {
+	l.t.Helper()
+	for _, state := range states {
+		switch state {
+		case StateOrdered:
+			l.must(asCarol, "change_state", "{gdsid}", state, "company1")
+		case StateDelivered:
+			l.must(asAlice, "change_state", "{gdsid}", state)
+		default:
+			l.must(asCarol, "change_state", "{gdsid}", state)
+		}
+	}
+}
+
+// lastEvent - the payload of the last event emitted
+func (l *testLedger) lastEvent() GoodsEvent {
+	l.t.Helper()
+	if len(l.stub.Events) == 0 {
+		l.t.Fatal("no event emitted")
+	}
+	var event GoodsEvent
+	l.decode(l.stub.Events[len(l.stub.Events)-1].Payload, &event)
+	return event
+}
+
+// put - writes a key directly, for records no function writes any more
+func (l *testLedger) put(key string, value string) {
+	l.stub.State[key] = []byte(value)
+}
+
+// compositeKey - createCompositeKey for keys the tests write themselves
+func compositeKey(objectType string, attributes ...string) string {
+	key, err := createCompositeKey(objectType, attributes)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// funcCase is one transaction of a table and what it must do
+type funcCase struct {
+	name  string
+	fn    string
+	as    testCaller
+	args  []string
+	setup func(l *testLedger)
+	code  string		//code of the error the call fails with, "" when it succeeds
+	event string		//name of the event a successful invoke emits, none when ""
+	check func(l *testLedger, result []byte)
+}
+
+// caseTables holds every table, TestEveryFunctionIsCovered looks through them
+var caseTables [][]funcCase
+
+// register - adds a table to caseTables
+func register(cases []funcCase) []funcCase {
+	caseTables = append(caseTables, cases)
+	return cases
+}
+
+// runCases - runs every case on its own fixture
+func runCases(t *testing.T, cases []funcCase) {
+	for _, c := range cases {
+		c := c
+		t.Run(c.fn+"/"+c.name, func(t *testing.T) {
+			defer SetClock(FixedClock{Time: testTime})
+
+			l := newFixture(t)
+			if c.setup != nil {
+				c.setup(l)
+			}
+			before := make(map[string][]byte, len(l.stub.State))
+			for key, value := range l.stub.State {
+				before[key] = value
+			}
+			events := len(l.stub.Events)
+
+			result, err := l.call(c.as, c.fn, c.args...)
+			if errorCode(err) != c.code {
+				t.Fatalf("%s %v returned %v, want code %q", c.fn, c.args, err, c.code)
+			}
+			if c.code != "" {
+				// the peer drops the writes and the event of a failed transaction
+				if !reflect.DeepEqual(before, l.stub.State) {
+					t.Errorf("%s failed but changed the ledger", c.fn)
+				}
+				if len(l.stub.Events) != events {
+					t.Errorf("%s failed but emitted an event", c.fn)
+				}
+				return
+			}
+			if c.event != "" {
+				if len(l.stub.Events) != events+1 || l.stub.Events[events].Name != c.event {
+					t.Errorf("%s emitted %v, want one %s", c.fn, l.stub.Events[events:], c.event)
+				}
+			} else if len(l.stub.Events) != events {
+				t.Errorf("%s emitted %v, want no event", c.fn, l.stub.Events[events:])
+			}
+			if c.check != nil {
+				c.check(l, result)
+			}
+		})
+	}
+}
+
+func owners(owners ...Owner) []Owner {
+	return owners
+}
+
+func checkOwners(l *testLedger, id string, want []Owner) {
+	l.t.Helper()
+	if got := l.goods(id).Owners; !reflect.DeepEqual(got, want) {
+		l.t.Errorf("owners of %s are %+v, want %+v", id, got, want)
+	}
+}
+
+func checkBalance(l *testLedger, company string, want float64) {
+	l.t.Helper()
+	if got := l.account(company).CashBalance; got != want {
+		l.t.Errorf("balance of %s is %v, want %v", company, got, want)
+	}
+}
+
+func checkState(l *testLedger, id string, want string) {
+	l.t.Helper()
+	if got := l.goods(id).State; got != want {
+		l.t.Errorf("state of %s is %s, want %s", id, got, want)
+	}
+}
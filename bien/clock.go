@@ -0,0 +1,70 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		 http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bien
+
+import (
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Clock tells the chaincode the time of the transaction it is executing.
+// Every endorsing peer must compute the same IDs and timestamps, so nothing
+// may read the peer's wall clock; everything goes through clock instead.
+type Clock interface {
+	Now(stub shim.ChaincodeStubInterface) (time.Time, error)
+}
+
+// txClock reads the timestamp the client put in the transaction proposal
+type txClock struct{}
+
+func (txClock) Now(stub shim.ChaincodeStubInterface) (time.Time, error) {
+	ts, err := stub.GetTxTimestamp()
+	if err != nil || ts == nil {
+		return time.Time{}, newError(CodeLedger, "Error getting transaction timestamp")
+	}
+	return time.Unix(ts.Seconds, int64(ts.Nanos)).UTC(), nil
+}
+
+// FixedClock always reports the same time, tests set it to control the ledger clock
+type FixedClock struct {
+	Time time.Time
+}
+
+func (c FixedClock) Now(stub shim.ChaincodeStubInterface) (time.Time, error) {
+	return c.Time, nil
+}
+
+// clock is the Clock used by all chaincode functions
+var clock Clock = txClock{}
+
+// SetClock - replaces the Clock of the chaincode and returns the one it replaced,
+// nil goes back to the transaction timestamp. Only tests change it.
+func SetClock(c Clock) Clock {
+	previous := clock
+	if c == nil {
+		c = txClock{}
+	}
+	clock = c
+	return previous
+}
+
+// txTimestamp - ms since epoch of the transaction, the same on every peer
+func txTimestamp(stub shim.ChaincodeStubInterface) (int64, error) {
+	now, err := clock.Now(stub)
+	if err != nil {
+		return 0, err
+	}
+	return now.UnixNano() / int64(time.Millisecond), nil
+}
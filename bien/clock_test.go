@@ -0,0 +1,50 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		 http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bien
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTxTimestampFollowsTheClock(t *testing.T) {
+	defer SetClock(FixedClock{Time: testTime})
+
+	SetClock(FixedClock{Time: time.Date(2016, time.November, 1, 12, 0, 0, 500e6, time.UTC)})
+	// the fixed clock never reads the stub
+	ms, err := txTimestamp(nil)
+	if err != nil || ms != 1478001600500 {
+		t.Errorf("txTimestamp returned %d, %v, want 1478001600500", ms, err)
+	}
+}
+
+func TestSetClock(t *testing.T) {
+	defer SetClock(FixedClock{Time: testTime})
+
+	later := FixedClock{Time: testTime.Add(time.Hour)}
+	if previous := SetClock(later); previous != (FixedClock{Time: testTime}) {
+		t.Errorf("SetClock replaced %v, want the test clock", previous)
+	}
+
+	// nil goes back to the timestamp of the transaction
+	l := newFixture(t)
+	l.stub.Clock = func() time.Time { return testTime.Add(48 * time.Hour) }
+	if previous := SetClock(nil); previous != later {
+		t.Errorf("SetClock replaced %v, want %v", previous, later)
+	}
+	gdsid := string(l.must(asCarol, "add_goods", `{"name":"table","price":40,"issuer":"company2","quantity":1}`))
+	if info, err := parseGDSID(gdsid); err != nil || info.DateCode != "LJ" {
+		t.Errorf("goods issued on November 3 got %s, want the date code LJ", gdsid)
+	}
+}
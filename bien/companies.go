@@ -0,0 +1,408 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		 http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bien
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Every company goods, accounts and trades refer to must be registered and active.
+// The short code is the issuer prefix of the GDSIDs of its goods, so no two companies
+// share one. The roles of a company cap the roles of the identities acting for it.
+const (
+	CompanyActive    = "active"
+	CompanySuspended = "suspended"
+)
+
+// Company is a participant of the network, stored under company~id
+type Company struct {
+	ID            string   `json:"id"`		//the name goods, accounts and trades refer to it by
+	LegalName     string   `json:"legalName"`
+	ShortCode     string   `json:"shortCode"`
+	Contact       string   `json:"contact"`
+	Roles         []string `json:"roles"`
+	Status        string   `json:"status"`
+	StatusReason  string   `json:"statusReason,omitempty"`
+	SchemaVersion int      `json:"schemaVersion"`
+}
+
+func init() {
+	registerFunctions(
+		Function{Name: "register_company", Kind: KindInvoke, Description: "Adds a company to the registry",
+			Args: []ArgSpec{{Name: "company", Type: ArgJSON, Description: "id, legalName, shortCode, contact and roles", Model: Company{}}},
+			Errors: []string{CodeCompanyExists, CodeIdentifierTaken, CodeIdentifiersExhausted},
+			Roles: []string{RoleAdmin},
+			Handler: (*BienChaincode).register_company},
+		Function{Name: "update_company", Kind: KindInvoke, Description: "Changes the fields given of a registered company",
+			Args: []ArgSpec{{Name: "company", Type: ArgJSON, Description: "id and the fields to change, roles, shortCode and status are for admins", Model: Company{}}},
+			Errors: []string{CodeCompanyNotFound, CodeIdentifierTaken},
+			Roles: []string{RoleIssuer, RoleBuyer, RoleShipper, RoleAuditor},
+			Handler: (*BienChaincode).update_company},
+		Function{Name: "suspend_company", Kind: KindInvoke, Description: "Stops a company from taking part in any goods operation",
+			Args: []ArgSpec{{Name: "id", Type: ArgString}, {Name: "reason", Type: ArgString, Optional: true}},
+			Errors: []string{CodeCompanyNotFound},
+			Roles: []string{RoleAdmin},
+			Handler: (*BienChaincode).suspend_company},
+		Function{Name: "get_company", Kind: KindQuery, Description: "A registered company",
+			Args: []ArgSpec{{Name: "id", Type: ArgString}},
+			Returns: Company{},
+			Errors: []string{CodeCompanyNotFound},
+			Handler: (*BienChaincode).get_company},
+	)
+}
+
+// register_company - admin invoke function adding a company to the registry
+func (t *BienChaincode) register_company(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+
+	/*		0
+		json
+		{
+			"id": "company2",
+			"legalName": "Company Two Ltd",
+			"shortCode": "COMP02",			// optional, the id normalized to six characters without it
+			"contact": "ops@company2.example",
+			"roles": ["issuer", "buyer"]
+		}
+	*/
+
+	var company Company
+	err := json.Unmarshal([]byte(args[0]), &company)
+	if err != nil {
+		fmt.Println(err)
+		return nil, argError("company", "Invalid company")
+	}
+	if company.ID == "" {
+		return nil, argError("company", "Company needs an id")
+	}
+	existing, err := findCompany(stub, company.ID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, newError(CodeCompanyExists, "Company "+company.ID+" is already registered")
+	}
+
+	if company.ShortCode == "" {
+		company.ShortCode, err = deriveShortCode(stub, company.ID)
+	} else {
+		company.ShortCode, err = normalizeIssuerPrefix(company.ShortCode)
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = claimShortCode(stub, company.ShortCode, company.ID)
+	if err != nil {
+		return nil, err
+	}
+	err = checkCompanyRoles(company.Roles)
+	if err != nil {
+		return nil, err
+	}
+	company.Status = CompanyActive
+	company.StatusReason = ""
+
+	err = putCompany(stub, company)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Println("Registered company " + company.ID)
+	return nil, nil
+}
+
+// update_company - invoke function changing the non-empty fields given of a company,
+// a company edits its own legal name and contact, admins edit everything
+func (t *BienChaincode) update_company(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+
+	/*		0
+		json
+		{
+			"id": "company2",
+			"contact": "logistics@company2.example",
+			"status": "active"				// admins only, reactivates a suspended company
+		}
+	*/
+
+	var update Company
+	err := json.Unmarshal([]byte(args[0]), &update)
+	if err != nil {
+		fmt.Println(err)
+		return nil, argError("company", "Invalid company")
+	}
+	company, err := getCompany(stub, update.ID)
+	if err != nil {
+		return nil, err
+	}
+	caller, err := callerIdentity(stub)
+	if err != nil {
+		return nil, err
+	}
+	err = requireCompany(caller, company.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !caller.hasRole(RoleAdmin) && (update.ShortCode != "" || update.Roles != nil || update.Status != "") {
+		return nil, newError(CodeForbidden, "Only admins change the short code, roles or status of a company")
+	}
+
+	if update.LegalName != "" {
+		company.LegalName = update.LegalName
+	}
+	if update.Contact != "" {
+		company.Contact = update.Contact
+	}
+	if update.ShortCode != "" {
+		code, err := normalizeIssuerPrefix(update.ShortCode)
+		if err != nil {
+			return nil, err
+		}
+		if code != company.ShortCode {
+			// GDSIDs issued under the old code keep it, the GDSID sequence stays with the code
+			// so a company taking the released code later never issues one of them again
+			err = claimShortCode(stub, code, company.ID)
+			if err != nil {
+				return nil, err
+			}
+			err = releaseShortCode(stub, company.ShortCode, company.ID)
+			if err != nil {
+				return nil, err
+			}
+			company.ShortCode = code
+		}
+	}
+	if update.Roles != nil {
+		err = checkCompanyRoles(update.Roles)
+		if err != nil {
+			return nil, err
+		}
+		company.Roles = update.Roles
+	}
+	switch update.Status {
+	case "":
+	case CompanyActive:
+		company.Status = CompanyActive
+		company.StatusReason = ""
+	case CompanySuspended:
+		company.Status = CompanySuspended
+		company.StatusReason = update.StatusReason
+	default:
+		return nil, argError("company", "Unknown company status "+update.Status)
+	}
+
+	err = putCompany(stub, company)
+	if err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// suspend_company - admin invoke function stopping a company from taking part in goods operations
+func (t *BienChaincode) suspend_company(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	//   0       1
+	// id   reason (optional)
+
+	company, err := getCompany(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+	company.Status = CompanySuspended
+	company.StatusReason = ""
+	if len(args) == 2 {
+		company.StatusReason = args[1]
+	}
+
+	err = putCompany(stub, company)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Println("Suspended company " + company.ID)
+	return nil, nil
+}
+
+// get_company - query function returning a registered company
+func (t *BienChaincode) get_company(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	company, err := getCompany(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(&company)
+}
+
+// activeCompany - the company a goods operation refers to, failing unless it is registered and active
+func activeCompany(stub shim.ChaincodeStubInterface, id string) (Company, error) {
+	company, err := getCompany(stub, id)
+	if err != nil {
+		return company, err
+	}
+	if company.Status != CompanyActive {
+		return company, &Error{Code: CodeCompanySuspended, Message: "Company " + id + " is suspended",
+			Details: company.StatusReason}
+	}
+	return company, nil
+}
+
+// checkCompanies - fails on the first of the companies that is not registered and active
+func checkCompanies(stub shim.ChaincodeStubInterface, ids ...string) error {
+	for _, id := range ids {
+		_, err := activeCompany(stub, id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getCompany - reads a registered company
+func getCompany(stub shim.ChaincodeStubInterface, id string) (Company, error) {
+	company, err := findCompany(stub, id)
+	if err != nil {
+		return Company{}, err
+	}
+	if company == nil {
+		return Company{}, newError(CodeCompanyNotFound, "Company "+id+" is not registered")
+	}
+	return *company, nil
+}
+
+// findCompany - reads a company, nil when it is not registered
+func findCompany(stub shim.ChaincodeStubInterface, id string) (*Company, error) {
+	if id == "" {
+		return nil, nil
+	}
+	key, err := createCompositeKey(companyObjectType, []string{id})
+	if err != nil {
+		return nil, err
+	}
+	companyBytes, err := stub.GetState(key)
+	if err != nil {
+		return nil, newError(CodeLedger, "Error retrieving company "+id)
+	}
+	if companyBytes == nil {
+		return nil, nil
+	}
+	var company Company
+	err = unmarshalRecord(kindCompany, companyBytes, &company)
+	if err != nil {
+		return nil, newError(CodeCorruptRecord, "Error unmarshalling company "+id)
+	}
+	return &company, nil
+}
+
+// putCompany - writes a company back under its id
+func putCompany(stub shim.ChaincodeStubInterface, company Company) error {
+	key, err := createCompositeKey(companyObjectType, []string{company.ID})
+	if err != nil {
+		return err
+	}
+	company.SchemaVersion = schemaVersions[kindCompany]
+	companyBytes, err := json.Marshal(&company)
+	if err != nil {
+		return newError(CodeInternal, "Error marshalling company "+company.ID)
+	}
+	err = stub.PutState(key, companyBytes)
+	if err != nil {
+		return newError(CodeLedger, "Error writing company "+company.ID)
+	}
+	return nil
+}
+
+// claimShortCode - reserves a short code for a company
+func claimShortCode(stub shim.ChaincodeStubInterface, code string, id string) error {
+	owner, err := shortCodeOwner(stub, code)
+	if err != nil {
+		return err
+	}
+	if owner != "" && owner != id {
+		return &Error{Code: CodeIdentifierTaken, Arg: "company",
+			Message: "Short code " + code + " is already used by " + owner}
+	}
+	key, err := createCompositeKey(shortCodeObjectType, []string{code})
+	if err != nil {
+		return err
+	}
+	err = stub.PutState(key, []byte(id))
+	if err != nil {
+		return newError(CodeLedger, "Error writing short code "+code)
+	}
+	return nil
+}
+
+// releaseShortCode - gives up the claim a company has on a short code
+func releaseShortCode(stub shim.ChaincodeStubInterface, code string, id string) error {
+	owner, err := shortCodeOwner(stub, code)
+	if err != nil {
+		return err
+	}
+	if owner != id {
+		return nil
+	}
+	key, err := createCompositeKey(shortCodeObjectType, []string{code})
+	if err != nil {
+		return err
+	}
+	err = stub.DelState(key)
+	if err != nil {
+		return newError(CodeLedger, "Error releasing short code "+code)
+	}
+	return nil
+}
+
+// shortCodeOwner - the company that claimed a short code, "" when it is free
+func shortCodeOwner(stub shim.ChaincodeStubInterface, code string) (string, error) {
+	key, err := createCompositeKey(shortCodeObjectType, []string{code})
+	if err != nil {
+		return "", err
+	}
+	owner, err := stub.GetState(key)
+	if err != nil {
+		return "", newError(CodeLedger, "Error retrieving short code "+code)
+	}
+	return string(owner), nil
+}
+
+// deriveShortCode - the short code of a company registered without one: its id cut to six
+// characters, or with the end replaced by a number when another company has that already
+func deriveShortCode(stub shim.ChaincodeStubInterface, id string) (string, error) {
+	base, err := normalizeIssuerPrefix(id)
+	if err != nil {
+		return "", err
+	}
+	code := base
+	for n := 1; ; n++ {
+		owner, err := shortCodeOwner(stub, code)
+		if err != nil {
+			return "", err
+		}
+		if owner == "" || owner == id {
+			return code, nil
+		}
+		suffix := strconv.Itoa(n)
+		if len(suffix) >= issuerPrefixLength {
+			return "", newError(CodeIdentifiersExhausted, "No short code left to derive from "+id)
+		}
+		code = base[:issuerPrefixLength-len(suffix)] + suffix
+	}
+}
+
+func checkCompanyRoles(roles []string) error {
+	for _, role := range roles {
+		if !isKnownRole(role) || role == RoleAdmin {
+			return argError("company", "Companies cannot hold the role "+role)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		 http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bien
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// shortCodeOwner - the company a short code is claimed by, "" when it is free
+func (l *testLedger) shortCodeOwner(code string) string {
+	return string(l.stub.State[compositeKey(shortCodeObjectType, code)])
+}
+
+var companyCases = register([]funcCase{
+	{name: "registers an active company", fn: "register_company", as: asAdmin,
+		args: []string{`{"id":"company5","legalName":"Company Five Ltd","shortCode":"comp-05","contact":"ops@company5.example","roles":["buyer"]}`},
+		check: func(l *testLedger, result []byte) {
+			company := l.company("company5")
+			want := Company{ID: "company5", LegalName: "Company Five Ltd", ShortCode: "COMP05", Contact: "ops@company5.example",
+				Roles: []string{"buyer"}, Status: CompanyActive, SchemaVersion: schemaVersions[kindCompany]}
+			if !reflect.DeepEqual(company, want) {
+				l.t.Errorf("registered %+v, want %+v", company, want)
+			}
+			if owner := l.shortCodeOwner("COMP05"); owner != "company5" {
+				l.t.Errorf("COMP05 is claimed by %q", owner)
+			}
+		}},
+	{name: "derives a free short code from the id", fn: "register_company", as: asAdmin,
+		args: []string{`{"id":"company5","legalName":"Company Five Ltd","roles":["buyer"]}`},
+		check: func(l *testLedger, result []byte) {
+			if code := l.company("company5").ShortCode; code != "COMPA1" {
+				l.t.Errorf("company5 got the short code %s, want COMPA1", code)
+			}
+		}},
+	{name: "registers a company once", fn: "register_company", as: asAdmin,
+		args: []string{`{"id":"company1","legalName":"Company One Ltd","shortCode":"COMP09"}`},
+		code: CodeCompanyExists},
+	{name: "does not share short codes", fn: "register_company", as: asAdmin,
+		args: []string{`{"id":"company5","legalName":"Company Five Ltd","shortCode":"COMPAN"}`},
+		code: CodeIdentifierTaken},
+	{name: "runs out of short codes to derive", fn: "register_company", as: asAdmin,
+		setup: func(l *testLedger) {
+			for n := 1; n < 100000; n++ {
+				suffix := strconv.Itoa(n)
+				l.put(compositeKey(shortCodeObjectType, "COMPAN"[:6-len(suffix)]+suffix), "other")
+			}
+		},
+		args: []string{`{"id":"company5","legalName":"Company Five Ltd"}`},
+		code: CodeIdentifiersExhausted},
+	{name: "does not let companies hold admin", fn: "register_company", as: asAdmin,
+		args: []string{`{"id":"company5","legalName":"Company Five Ltd","shortCode":"COMP05","roles":["admin"]}`},
+		code: CodeInvalidArgument},
+	{name: "is for admins", fn: "register_company", as: asAlice,
+		args: []string{`{"id":"company5","legalName":"Company Five Ltd"}`},
+		code: CodeForbidden},
+
+	{name: "changes the contact of the caller's company", fn: "update_company", as: asAlice,
+		args: []string{`{"id":"company1","contact":"logistics@company1.example"}`},
+		check: func(l *testLedger, result []byte) {
+			company := l.company("company1")
+			if company.Contact != "logistics@company1.example" || company.LegalName != "Company One Ltd" {
+				l.t.Errorf("updated %+v", company)
+			}
+		}},
+	{name: "frees the old short code", fn: "update_company", as: asAdmin,
+		args: []string{`{"id":"company1","shortCode":"COMP11"}`},
+		check: func(l *testLedger, result []byte) {
+			if code := l.company("company1").ShortCode; code != "COMP11" {
+				l.t.Errorf("short code of company1 is %s", code)
+			}
+			if owner := l.shortCodeOwner("COMP11"); owner != "company1" {
+				l.t.Errorf("COMP11 is claimed by %q", owner)
+			}
+			if owner := l.shortCodeOwner("COMP01"); owner != "" {
+				l.t.Errorf("COMP01 is still claimed by %s", owner)
+			}
+			l.must(asAdmin, "register_company", `{"id":"company5","legalName":"Company Five Ltd","shortCode":"COMP01"}`)
+		}},
+	{name: "reactivates a suspended company", fn: "update_company", as: asAdmin,
+		args: []string{`{"id":"company3","status":"active"}`},
+		check: func(l *testLedger, result []byte) {
+			if company := l.company("company3"); company.Status != CompanyActive || company.StatusReason != "" {
+				l.t.Errorf("updated %+v", company)
+			}
+		}},
+	{name: "needs a registered company", fn: "update_company", as: asAdmin,
+		args: []string{`{"id":"ghost","contact":"nobody"}`},
+		code: CodeCompanyNotFound},
+	{name: "does not share short codes", fn: "update_company", as: asAdmin,
+		args: []string{`{"id":"company1","shortCode":"COMPAN"}`},
+		code: CodeIdentifierTaken},
+	{name: "leaves roles to admins", fn: "update_company", as: asAlice,
+		args: []string{`{"id":"company1","roles":["issuer","buyer","shipper","auditor"]}`},
+		code: CodeForbidden},
+	{name: "only updates the caller's company", fn: "update_company", as: asAlice,
+		args: []string{`{"id":"company2","contact":"alice@company1.example"}`},
+		code: CodeForbidden},
+
+	{name: "suspends a company and the roles of its callers", fn: "suspend_company", as: asAdmin,
+		args: []string{"company1", "audit"},
+		check: func(l *testLedger, result []byte) {
+			if company := l.company("company1"); company.Status != CompanySuspended || company.StatusReason != "audit" {
+				l.t.Errorf("suspended %+v", company)
+			}
+			var alice Identity
+			l.decode(l.must(asAlice, "whoami"), &alice)
+			if len(alice.Roles) != 0 {
+				l.t.Errorf("alice keeps the roles %v", alice.Roles)
+			}
+		}},
+	{name: "needs a registered company", fn: "suspend_company", as: asAdmin,
+		args: []string{"ghost"},
+		code: CodeCompanyNotFound},
+
+	{name: "reads a company", fn: "get_company", as: asAnonymous,
+		args: []string{"company3"},
+		check: func(l *testLedger, result []byte) {
+			var company Company
+			l.decode(result, &company)
+			if company.ID != "company3" || company.Status != CompanySuspended || company.StatusReason != "unpaid fees" {
+				l.t.Errorf("get_company returned %+v", company)
+			}
+		}},
+	{name: "needs a registered company", fn: "get_company", as: asAnonymous,
+		args: []string{"ghost"},
+		code: CodeCompanyNotFound},
+})
+
+func TestCompanyFunctions(t *testing.T) {
+	runCases(t, companyCases)
+}
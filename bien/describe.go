@@ -0,0 +1,176 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		 http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bien
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// describe answers from the router's registry, so it always matches the deployed
+// chaincode. The JSON schemas of arguments and results are derived from the Go
+// types the functions decode and encode.
+
+// FunctionInfo is the describe answer for one function
+type FunctionInfo struct {
+	Name        string         `json:"name"`
+	Kind        string         `json:"kind"`
+	Description string         `json:"description,omitempty"`
+	Roles       []string       `json:"roles,omitempty"`
+	Args        []ArgInfo      `json:"args"`
+	Returns     JSONSchema     `json:"returns,omitempty"`
+	Errors      []string       `json:"errors,omitempty"`
+	Overloads   []FunctionInfo `json:"overloads,omitempty"`
+}
+
+// ArgInfo is one argument in the describe answer, schema is set for json arguments
+type ArgInfo struct {
+	ArgSpec
+	Schema JSONSchema `json:"schema,omitempty"`
+}
+
+// JSONSchema is a JSON Schema document
+type JSONSchema map[string]interface{}
+
+func init() {
+	registerFunctions(
+		Function{Name: "describe", Kind: KindQuery, Description: "Metadata of every chaincode function, or of the named ones",
+			Args: []ArgSpec{{Name: "function", Type: ArgString, Optional: true}},
+			Returns: []FunctionInfo{},
+			Errors: []string{CodeUnknownFunction},
+			Handler: (*BienChaincode).describe},
+	)
+}
+
+// describe - query function returning the name, arguments, result, errors and role of chaincode functions
+func (t *BienChaincode) describe(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	var names []string
+	if len(args) == 1 && args[0] != "" {
+		if _, ok := functions[args[0]]; !ok {
+			return nil, &Error{Code: CodeUnknownFunction, Message: "Unknown function " + args[0], Arg: "function"}
+		}
+		names = []string{args[0]}
+	} else {
+		for name := range functions {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	infos := []FunctionInfo{}
+	for _, name := range names {
+		infos = append(infos, describeFunction(functions[name]))
+	}
+	return json.Marshal(&infos)
+}
+
+func describeFunction(fn *Function) FunctionInfo {
+	info := FunctionInfo{
+		Name:        fn.Name,
+		Kind:        fn.Kind,
+		Description: fn.Description,
+		Roles:       fn.Roles,
+		Args:        []ArgInfo{},
+		Errors:      append(append([]string{}, routerErrors...), fn.Errors...),
+	}
+	if len(fn.Roles) > 0 {
+		info.Errors = append(info.Errors, CodeUnauthenticated, CodeForbidden)
+	}
+	for _, spec := range fn.Args {
+		arg := ArgInfo{ArgSpec: spec}
+		if spec.Model != nil {
+			arg.Schema = schemaOf(reflect.TypeOf(spec.Model))
+		}
+		info.Args = append(info.Args, arg)
+	}
+	if fn.Returns != nil {
+		info.Returns = schemaOf(reflect.TypeOf(fn.Returns))
+	}
+	for i := range fn.Overloads {
+		overload := fn.Overloads[i]
+		overload.Name = fn.Name
+		overload.Kind = fn.Kind
+		overload.Roles = fn.Roles
+		info.Overloads = append(info.Overloads, describeFunction(&overload))
+	}
+	return info
+}
+
+// schemaOf - JSON Schema of the JSON encoding/json produces for a Go type
+func schemaOf(t reflect.Type) JSONSchema {
+	return typeSchema(t, map[reflect.Type]bool{})
+}
+
+// typeSchema - schemaOf for a type inside the structs in open, a struct that contains
+// itself (FunctionInfo and its overloads) is given by its title the second time
+func typeSchema(t reflect.Type, open map[reflect.Type]bool) JSONSchema {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return typeSchema(t.Elem(), open)
+	case reflect.String:
+		return JSONSchema{"type": "string"}
+	case reflect.Bool:
+		return JSONSchema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return JSONSchema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return JSONSchema{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return JSONSchema{"type": "string", "contentEncoding": "base64"}
+		}
+		return JSONSchema{"type": "array", "items": typeSchema(t.Elem(), open)}
+	case reflect.Map:
+		return JSONSchema{"type": "object", "additionalProperties": typeSchema(t.Elem(), open)}
+	case reflect.Struct:
+		if open[t] {
+			return JSONSchema{"type": "object", "title": t.Name()}
+		}
+		open[t] = true
+		defer delete(open, t)
+		properties := JSONSchema{}
+		addProperties(t, properties, open)
+		return JSONSchema{"type": "object", "title": t.Name(), "properties": properties}
+	}
+	return JSONSchema{}
+}
+
+// addProperties - the properties of a struct's exported fields, embedded structs included
+func addProperties(t reflect.Type, properties JSONSchema, open map[reflect.Type]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := field.Name
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		if tag != "" {
+			if parts := strings.Split(tag, ","); parts[0] != "" {
+				name = parts[0]
+			}
+		} else if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			addProperties(field.Type, properties, open)
+			continue
+		}
+		properties[name] = typeSchema(field.Type, open)
+	}
+}
@@ -0,0 +1,109 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		 http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bien
+
+import (
+	"encoding/json"
+)
+
+// Every chaincode function fails with an *Error. Its text is the JSON of the error, so the
+// code survives the trip through the peer, clients branch on the code and show the message.
+// Codes are stable, messages are not.
+const (
+	CodeInvalidArgument         = "INVALID_ARGUMENT"
+	CodeUnknownFunction         = "UNKNOWN_FUNCTION"
+	CodeUnauthenticated         = "UNAUTHENTICATED"	//the function needs a signed transaction
+	CodeForbidden               = "FORBIDDEN"		//the caller lacks the role, or does not act for the company
+	CodeGoodsNotFound           = "GOODS_NOT_FOUND"
+	CodeGoodsExists             = "GOODS_ALREADY_EXISTS"
+	CodeGoodsInactive           = "GOODS_INACTIVE"		//goods are closed or cancelled
+	CodeAccountNotFound         = "ACCOUNT_NOT_FOUND"
+	CodeAccountExists           = "ACCOUNT_ALREADY_EXISTS"
+	CodeCompanyNotFound         = "COMPANY_NOT_FOUND"
+	CodeCompanyExists           = "COMPANY_ALREADY_EXISTS"
+	CodeCompanySuspended        = "COMPANY_SUSPENDED"
+	CodeTradeNotFound           = "TRADE_NOT_FOUND"
+	CodeNotOwner                = "NOT_OWNER"
+	CodeInsufficientQuantity    = "INSUFFICIENT_QUANTITY"
+	CodeInsufficientFunds       = "INSUFFICIENT_FUNDS"
+	CodeInvalidState            = "INVALID_STATE"
+	CodeInvalidStateTransition  = "INVALID_STATE_TRANSITION"
+	CodeStatePreconditionFailed = "STATE_PRECONDITION_FAILED"
+	CodeInvalidIdentifier       = "INVALID_IDENTIFIER"
+	CodeIdentifierTaken         = "IDENTIFIER_TAKEN"
+	CodeIdentifiersExhausted    = "IDENTIFIERS_EXHAUSTED"
+	CodeKeyNotFound             = "KEY_NOT_FOUND"
+	CodeReservedKey             = "RESERVED_KEY"		//the key belongs to the domain functions
+	CodeUnsupportedSchema       = "UNSUPPORTED_SCHEMA_VERSION"
+	CodeLedger                  = "LEDGER_ERROR"		//the peer failed to read or write state
+	CodeCorruptRecord           = "CORRUPT_RECORD"	//a stored record could not be decoded
+	CodeInternal                = "INTERNAL"
+)
+
+// routerErrors are the codes any function may fail with, describe adds them to the declared ones
+var routerErrors = []string{CodeInvalidArgument, CodeLedger, CodeCorruptRecord, CodeUnsupportedSchema, CodeInternal}
+
+// Error is the error of a chaincode function
+type Error struct {
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Arg     string      `json:"arg,omitempty"`		//name of the offending argument
+	Details interface{} `json:"details,omitempty"`
+}
+
+// StateDetails are the details of the lifecycle errors
+type StateDetails struct {
+	GDSID   string   `json:"gdsid"`
+	From    string   `json:"from"`
+	To      string   `json:"to"`
+	Allowed []string `json:"allowed"`
+}
+
+func (e *Error) Error() string {
+	errBytes, err := json.Marshal(e)
+	if err != nil {
+		return e.Code + ": " + e.Message
+	}
+	return string(errBytes)
+}
+
+func newError(code string, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// argError - an INVALID_ARGUMENT error naming the argument it is about
+func argError(arg string, message string) *Error {
+	return &Error{Code: CodeInvalidArgument, Message: message, Arg: arg}
+}
+
+// asError - err as an *Error, errors that do not carry a code are INTERNAL
+func asError(err error) *Error {
+	if e, ok := err.(*Error); ok {
+		return e
+	}
+	return newError(CodeInternal, err.Error())
+}
+
+// ParseError - the *Error behind an error returned by the chaincode, also when only its text
+// came back from the peer. Errors that are not chaincode errors come back as INTERNAL.
+func ParseError(err error) *Error {
+	if e, ok := err.(*Error); ok {
+		return e
+	}
+	var e Error
+	if json.Unmarshal([]byte(err.Error()), &e) == nil && e.Code != "" {
+		return &e
+	}
+	return newError(CodeInternal, err.Error())
+}
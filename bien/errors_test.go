@@ -0,0 +1,36 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		 http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bien
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseError(t *testing.T) {
+	sent := argError("price", "Price must be a number")
+	// the peer hands back only the text of the error
+	if e := ParseError(errors.New(sent.Error())); e.Code != CodeInvalidArgument || e.Arg != "price" || e.Message != sent.Message {
+		t.Errorf("the text of %v parsed as %+v", sent, e)
+	}
+	if e := ParseError(sent); e != sent {
+		t.Errorf("ParseError(%v) = %+v", sent, e)
+	}
+	if e := ParseError(errors.New("connection refused")); e.Code != CodeInternal || e.Message != "connection refused" {
+		t.Errorf("an error of no chaincode function parsed as %+v", e)
+	}
+	if e := asError(errors.New("boom")); e.Code != CodeInternal {
+		t.Errorf("asError gave %+v", e)
+	}
+}
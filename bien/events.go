@@ -0,0 +1,154 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		 http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bien
+
+import (
+	"encoding/json"
+	"reflect"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Every function that changes goods ends by setting one chaincode event, a peer only
+// emits the last event of a transaction. The name tells subscribers what happened, the
+// payload is a GoodsEvent whose old and new values have the type the catalog below gives
+// for that name. Migrating and upgrading goods records emit nothing, the goods stay the same.
+// describe_events returns the catalog with the JSON schemas of the values.
+const (
+	EventGoodsIssued       = "GoodsIssued"
+	EventGoodsTransferred  = "GoodsTransferred"
+	EventGoodsSold         = "GoodsSold"
+	EventGoodsOwnerSet     = "GoodsOwnerSet"
+	EventGoodsStateChanged = "GoodsStateChanged"
+	EventGoodsPriceChanged = "GoodsPriceChanged"
+	EventGoodsIDRegistered = "GoodsIDRegistered"
+)
+
+// GoodsEvent is the payload of every goods event
+type GoodsEvent struct {
+	Event      string       `json:"event"`
+	GDSID      string       `json:"gdsid"`
+	Actor      string       `json:"actor"`		//common name of the certificate that sent the transaction
+	Company    string       `json:"company,omitempty"`		//company the actor acts for
+	TxID       string       `json:"txId"`
+	Timestamp  int64        `json:"timestamp"`
+	Old        interface{}  `json:"old"`		//nil when the goods were issued
+	New        interface{}  `json:"new"`
+	Transfer   *Transaction `json:"transfer,omitempty"`		//GoodsTransferred and GoodsSold
+	Settlement *Settlement  `json:"settlement,omitempty"`	//GoodsSold
+}
+
+// Settlement is the payment of a purchase
+type Settlement struct {
+	Buyer  string  `json:"buyer"`
+	Seller string  `json:"seller"`
+	Amount float64 `json:"amount"`		//unit price times quantity, moved from the buyer's account to the seller's
+}
+
+// EventInfo is the describe_events answer for one event
+type EventInfo struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Functions   []string   `json:"functions"`		//the functions that emit it
+	Old         JSONSchema `json:"old,omitempty"`
+	New         JSONSchema `json:"new"`
+}
+
+// eventSpec declares an event, Old and New are values of the Go types of the payload's old and new
+type eventSpec struct {
+	Name        string
+	Description string
+	Functions   []string
+	Old         interface{}
+	New         interface{}
+}
+
+// eventCatalog is every event the chaincode emits
+var eventCatalog = []eventSpec{
+	{Name: EventGoodsIssued, Description: "Goods were issued, new is the goods record",
+		Functions: []string{"add_goods"}, New: Goods{}},
+	{Name: EventGoodsTransferred, Description: "Units of goods moved between companies, old and new are the owners",
+		Functions: []string{"transfer_goods"}, Old: []Owner{}, New: []Owner{}},
+	{Name: EventGoodsSold, Description: "Units of goods were bought and paid for, old and new are the owners",
+		Functions: []string{"buy_goods", "accept_trade"}, Old: []Owner{}, New: []Owner{}},
+	{Name: EventGoodsOwnerSet, Description: "Every unit of goods moved to one company, old and new are the owners",
+		Functions: []string{"set_owner"}, Old: []Owner{}, New: []Owner{}},
+	{Name: EventGoodsStateChanged, Description: "Goods moved to another state of their lifecycle, old and new are the states",
+		Functions: []string{"change_state"}, Old: "", New: ""},
+	{Name: EventGoodsPriceChanged, Description: "The issuer repriced goods, old and new are the prices",
+		Functions: []string{"set_price"}, Old: float64(0), New: float64(0)},
+	{Name: EventGoodsIDRegistered, Description: "Goods got an identifier of another scheme, old and new are the identifiers by scheme",
+		Functions: []string{"register_goods_id"}, Old: map[string]string{}, New: map[string]string{}},
+}
+
+func init() {
+	registerFunctions(
+		Function{Name: "describe_events", Kind: KindQuery, Description: "The events the chaincode emits, or the named one",
+			Args: []ArgSpec{{Name: "event", Type: ArgString, Optional: true}},
+			Returns: []EventInfo{},
+			Handler: (*BienChaincode).describe_events},
+	)
+}
+
+// describe_events - query function returning the event catalog
+func (t *BienChaincode) describe_events(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	infos := []EventInfo{}
+	for _, spec := range eventCatalog {
+		if len(args) == 1 && args[0] != "" && args[0] != spec.Name {
+			continue
+		}
+		info := EventInfo{Name: spec.Name, Description: spec.Description, Functions: spec.Functions,
+			New: schemaOf(reflect.TypeOf(spec.New))}
+		if spec.Old != nil {
+			info.Old = schemaOf(reflect.TypeOf(spec.Old))
+		}
+		infos = append(infos, info)
+	}
+	if len(infos) == 0 {
+		return nil, argError("event", "Unknown event "+args[0])
+	}
+	return json.Marshal(&infos)
+}
+
+// emitGoodsEvent - fills in the actor and transaction of a goods event and sets it as the event of the transaction
+func emitGoodsEvent(stub shim.ChaincodeStubInterface, name string, event GoodsEvent) error {
+	caller, err := callerIdentity(stub)
+	if err != nil {
+		return err
+	}
+	timestamp, err := txTimestamp(stub)
+	if err != nil {
+		return err
+	}
+	event.Event = name
+	event.Actor = caller.Name
+	event.Company = caller.Company
+	event.TxID = stub.GetTxID()
+	event.Timestamp = timestamp
+
+	eventBytes, err := json.Marshal(&event)
+	if err != nil {
+		return newError(CodeInternal, "Error marshalling event "+name+" of goods "+event.GDSID)
+	}
+	err = stub.SetEvent(name, eventBytes)
+	if err != nil {
+		return newError(CodeLedger, "Error setting event "+name+" of goods "+event.GDSID)
+	}
+	return nil
+}
+
+// copyOwners - the owners of goods as they are now, moveQuantity changes the list in place
+func copyOwners(goods Goods) []Owner {
+	return append([]Owner{}, goods.Owners...)
+}
@@ -0,0 +1,241 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		 http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bien
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// A GDSID is laid out like a CUSIP with a sequence in front of the check digit:
+//
+//	COMPAN  A1   000042  4
+//	issuer  date sequence check
+//
+// The issuer prefix is the short code the issuer is registered with, six upper case
+// letters and digits, the date characters come from generateCUSIPSuffix and the sequence
+// is kept per issuer prefix on the ledger, so two issues never share an ID. The check digit is
+// the CUSIP modulus 10 "double add double" digit over everything before it.
+const (
+	issuerPrefixLength  = 6
+	dateCodeLength      = 2
+	gdsidSequenceDigits = 6
+	gdsidLength         = issuerPrefixLength + dateCodeLength + gdsidSequenceDigits + 1
+)
+
+// GDSIDInfo is the validate_gdsid answer, the parts are only filled in for valid IDs
+type GDSIDInfo struct {
+	GDSID        string `json:"gdsid"`
+	Valid        bool   `json:"valid"`
+	IssuerPrefix string `json:"issuerPrefix,omitempty"`
+	DateCode     string `json:"dateCode,omitempty"`
+	Sequence     int    `json:"sequence,omitempty"`
+	Reason       string `json:"reason,omitempty"`
+}
+
+func init() {
+	registerFunctions(
+		Function{Name: "validate_gdsid", Kind: KindQuery, Description: "Checks the layout and check digit of a GDSID",
+			Args: []ArgSpec{{Name: "gdsid", Type: ArgString}},
+			Returns: GDSIDInfo{},
+			Handler: (*BienChaincode).validate_gdsid},
+	)
+}
+
+// validate_gdsid - query function checking the layout and check digit of a GDSID without reading any goods
+func (t *BienChaincode) validate_gdsid(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	info := GDSIDInfo{GDSID: args[0]}
+	gdsid, err := parseGDSID(args[0])
+	if err != nil {
+		info.Reason = asError(err).Message
+	} else {
+		info = gdsid
+	}
+	return json.Marshal(&info)
+}
+
+// newGDSID - assigns the next GDSID under the issuer's short code for goods issued at timestamp (ms since epoch)
+func newGDSID(stub shim.ChaincodeStubInterface, shortCode string, timestamp int64) (string, error) {
+	prefix, err := normalizeIssuerPrefix(shortCode)
+	if err != nil {
+		return "", err
+	}
+	suffix, err := generateCUSIPSuffix(strconv.FormatInt(timestamp, 10), 15)
+	if err != nil {
+		return "", newError(CodeInternal, "Error generating GDSID")
+	}
+	// GDSIDs issued before the registry may share a prefix, so the sequence belongs to the prefix and not the issuer
+	seq, err := nextGoodsSequence(stub, prefix)
+	if err != nil {
+		return "", err
+	}
+	if seq >= 1000000 {
+		return "", newError(CodeIdentifiersExhausted, "Issuer prefix "+prefix+" has run out of GDSIDs")
+	}
+
+	body := prefix + suffix + fmt.Sprintf("%0*d", gdsidSequenceDigits, seq)
+	check, err := cusipCheckDigit(body)
+	if err != nil {
+		return "", err
+	}
+	return body + check, nil
+}
+
+// normalizeIssuerPrefix - the issuer upper cased, stripped to letters and digits and cut or padded to six characters
+func normalizeIssuerPrefix(issuer string) (string, error) {
+	var prefix []rune
+	for _, c := range strings.ToUpper(issuer) {
+		if (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') {
+			prefix = append(prefix, c)
+		}
+	}
+	if len(prefix) == 0 {
+		return "", newError(CodeInvalidArgument, "Issuer "+issuer+" has no letters or digits to build a GDSID from")
+	}
+	for len(prefix) < issuerPrefixLength {
+		prefix = append(prefix, '0')
+	}
+	return string(prefix[:issuerPrefixLength]), nil
+}
+
+// parseGDSID - splits a GDSID into its parts, failing on any layout or check digit error
+func parseGDSID(id string) (GDSIDInfo, error) {
+	info := GDSIDInfo{GDSID: id}
+	if len(id) != gdsidLength {
+		return info, newError(CodeInvalidIdentifier, "GDSID must be "+strconv.Itoa(gdsidLength)+" characters long")
+	}
+	for _, c := range id {
+		if !((c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')) {
+			return info, newError(CodeInvalidIdentifier, "GDSID may only contain upper case letters and digits")
+		}
+	}
+
+	prefix := id[:issuerPrefixLength]
+	dateCode := id[issuerPrefixLength : issuerPrefixLength+dateCodeLength]
+	seqPart := id[issuerPrefixLength+dateCodeLength : gdsidLength-1]
+
+	if !isDateCode(dateCode) {
+		return info, newError(CodeInvalidIdentifier, "GDSID date characters "+dateCode+" are not a valid month and day")
+	}
+	seq, err := strconv.Atoi(seqPart)
+	if err != nil || seq <= 0 {
+		return info, newError(CodeInvalidIdentifier, "GDSID sequence "+seqPart+" is not a positive number")
+	}
+	check, err := cusipCheckDigit(id[:gdsidLength-1])
+	if err != nil {
+		return info, err
+	}
+	if check != id[gdsidLength-1:] {
+		return info, newError(CodeInvalidIdentifier, "GDSID check digit does not match, the ID is mistyped")
+	}
+
+	info.Valid = true
+	info.IssuerPrefix = prefix
+	info.DateCode = dateCode
+	info.Sequence = seq
+	return info, nil
+}
+
+// hasGDSIDLayout - true when the id would be a GDSID with another check digit
+func hasGDSIDLayout(id string) bool {
+	if len(id) != gdsidLength {
+		return false
+	}
+	check, err := cusipCheckDigit(id[:gdsidLength-1])
+	if err != nil {
+		return false
+	}
+	_, err = parseGDSID(id[:gdsidLength-1] + check)
+	return err == nil
+}
+
+// isDateCode - true when the date characters are a month 1-12 and a day that month has.
+// The year is not part of the code, so February 29 is always accepted.
+func isDateCode(code string) bool {
+	month := codeValue(seventhDigit, code[:1])
+	day := codeValue(eigthDigit, code[1:])
+	if month < 1 || month > 12 || day < 1 {
+		return false
+	}
+	// day 0 of the next month is the last day of this one, 2000 being a leap year
+	return day <= time.Date(2000, time.Month(month+1), 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// codeValue - the number a date character stands for, 0 when it stands for none
+func codeValue(codes map[int]string, c string) int {
+	for v, code := range codes {
+		if code == c {
+			return v
+		}
+	}
+	return 0
+}
+
+// cusipCheckDigit - CUSIP check digit: character values, every second one doubled, digits of the results summed
+func cusipCheckDigit(body string) (string, error) {
+	sum := 0
+	for i, c := range body {
+		var v int
+		switch {
+		case c >= '0' && c <= '9':
+			v = int(c - '0')
+		case c >= 'A' && c <= 'Z':
+			v = int(c-'A') + 10
+		case c == '*':
+			v = 36
+		case c == '@':
+			v = 37
+		case c == '#':
+			v = 38
+		default:
+			return "", newError(CodeInvalidIdentifier, "Character "+string(c)+" cannot be part of a GDSID")
+		}
+		if i%2 == 1 {
+			v *= 2
+		}
+		sum += v/10 + v%10
+	}
+	return strconv.Itoa((10 - sum%10) % 10), nil
+}
+
+// nextGoodsSequence - increments and returns the goods sequence of an issuer prefix
+func nextGoodsSequence(stub shim.ChaincodeStubInterface, prefix string) (int, error) {
+	seqKey, err := createCompositeKey(gdsidSeqObjectType, []string{prefix})
+	if err != nil {
+		return 0, err
+	}
+	seqBytes, err := stub.GetState(seqKey)
+	if err != nil {
+		return 0, newError(CodeLedger, "Error retrieving goods sequence of "+prefix)
+	}
+	seq := 0
+	if seqBytes != nil {
+		seq, err = strconv.Atoi(string(seqBytes))
+		if err != nil {
+			return 0, newError(CodeCorruptRecord, "Corrupt goods sequence of "+prefix)
+		}
+	}
+	seq++
+
+	err = stub.PutState(seqKey, []byte(strconv.Itoa(seq)))
+	if err != nil {
+		return 0, newError(CodeLedger, "Error writing goods sequence of "+prefix)
+	}
+	return seq, nil
+}
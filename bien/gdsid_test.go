@@ -0,0 +1,120 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		 http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bien
+
+import (
+	"testing"
+)
+
+var gdsidCases = register([]funcCase{
+	{name: "splits a GDSID", fn: "validate_gdsid", as: asAnonymous,
+		args: []string{"{gdsid}"},
+		check: func(l *testLedger, result []byte) {
+			var info GDSIDInfo
+			l.decode(result, &info)
+			if !info.Valid || info.IssuerPrefix != "COMPAN" || info.Sequence != 1 {
+				l.t.Errorf("validate_gdsid returned %+v", info)
+			}
+		}},
+	{name: "reports a wrong check digit", fn: "validate_gdsid", as: asAnonymous,
+		args: []string{"COMPANLG0000010"},
+		check: func(l *testLedger, result []byte) {
+			var info GDSIDInfo
+			l.decode(result, &info)
+			if info.Valid || info.Reason == "" {
+				l.t.Errorf("validate_gdsid returned %+v", info)
+			}
+		}},
+})
+
+func TestGDSIDFunctions(t *testing.T) {
+	runCases(t, gdsidCases)
+}
+
+func TestCusipCheckDigit(t *testing.T) {
+	// check digits of real CUSIPs
+	for body, want := range map[string]string{"03783310": "0", "59491810": "4", "38259P50": "8"} {
+		if got, err := cusipCheckDigit(body); err != nil || got != want {
+			t.Errorf("cusipCheckDigit(%s) = %q, %v, want %s", body, got, err, want)
+		}
+	}
+	if _, err := cusipCheckDigit("COMP-N"); err == nil {
+		t.Error("cusipCheckDigit accepted a dash")
+	}
+}
+
+func TestNormalizeIssuerPrefix(t *testing.T) {
+	for issuer, want := range map[string]string{
+		"company2":       "COMPAN",
+		"Acme":           "ACME00",
+		"b&q-stores ltd": "BQSTOR",
+	} {
+		if got, err := normalizeIssuerPrefix(issuer); err != nil || got != want {
+			t.Errorf("normalizeIssuerPrefix(%q) = %q, %v, want %s", issuer, got, err, want)
+		}
+	}
+	if _, err := normalizeIssuerPrefix("--"); err == nil {
+		t.Error("normalizeIssuerPrefix accepted an issuer without letters or digits")
+	}
+}
+
+func TestParseGDSID(t *testing.T) {
+	info, err := parseGDSID("COMPANLG0000015")
+	if err != nil || !info.Valid || info.IssuerPrefix != "COMPAN" || info.DateCode != "LG" || info.Sequence != 1 {
+		t.Errorf("parseGDSID returned %+v, %v", info, err)
+	}
+	for _, id := range []string{
+		"COMPANLG0000010",	//check digit
+		"COMPANLG000001",	//length
+		"companLG0000012",	//lower case
+		"COMPANLG0000002",	//sequence 0
+	} {
+		if info, err := parseGDSID(id); err == nil || info.Valid {
+			t.Errorf("parseGDSID(%s) = %+v, want an error", id, info)
+		}
+	}
+}
+
+func TestIsDateCode(t *testing.T) {
+	for code, want := range map[string]bool{
+		"A1": true,		//January 1
+		"MX": true,		//December 31
+		"BV": true,		//February 29
+		"DW": true,		//April 30
+		"N1": false,	//month 13
+		"A0": false,
+		"BW": false,	//February 30
+		"DX": false,	//April 31
+	} {
+		if got := isDateCode(code); got != want {
+			t.Errorf("isDateCode(%q) = %v, want %v", code, got, want)
+		}
+	}
+}
+
+func TestHasGDSIDLayout(t *testing.T) {
+	for id, want := range map[string]bool{
+		"COMPANLG0000015": true,
+		"COMPANLG0000010": true,		//wrong check digit
+		"COMPANLG0001015": true,		//mistyped sequence
+		"COMPANNG0000015": false,	//month 14
+		"COMPANLG00000A5": false,
+		"company2B3":      false,
+		"1479891234":      false,
+	} {
+		if got := hasGDSIDLayout(id); got != want {
+			t.Errorf("hasGDSIDLayout(%q) = %v, want %v", id, got, want)
+		}
+	}
+}
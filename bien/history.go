@@ -0,0 +1,157 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		 http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bien
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// GoodsChange is one immutable entry of the history of a goods record
+type GoodsChange struct {
+	GDSID     string `json:"gdsid"`
+	Sequence  int    `json:"sequence"`
+	Action    string `json:"action"`
+	Actor     string `json:"actor"`
+	TxID      string `json:"txId"`
+	Timestamp int64  `json:"timestamp"`
+	Old       *Goods `json:"old"`		//nil when the goods were issued
+	New       *Goods `json:"new"`
+	SchemaVersion int `json:"schemaVersion"`
+}
+
+func init() {
+	registerFunctions(
+		Function{Name: "get_goods_history", Kind: KindQuery, Description: "Every change of a goods record, oldest first",
+			Args: []ArgSpec{{Name: "gdsid", Type: ArgString}},
+			Returns: []GoodsChange{},
+			Handler: (*BienChaincode).get_goods_history},
+	)
+}
+
+// get_goods_history - query function returning every change of a goods record, oldest first
+func (t *BienChaincode) get_goods_history(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	history, err := getGoodsHistory(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(&history)
+}
+
+// getGoodsHistory - range scans the history entries of a GDSID
+func getGoodsHistory(stub shim.ChaincodeStubInterface, gdsid string) ([]GoodsChange, error) {
+	startKey, endKey, err := compositeKeyRange(historyObjectType, []string{gdsid})
+	if err != nil {
+		return nil, err
+	}
+	iter, err := stub.RangeQueryState(startKey, endKey)
+	if err != nil {
+		return nil, newError(CodeLedger, "Error scanning history of "+gdsid)
+	}
+	defer iter.Close()
+
+	history := []GoodsChange{}
+	for iter.HasNext() {
+		_, changeBytes, err := iter.Next()
+		if err != nil {
+			return nil, newError(CodeLedger, "Error scanning history of "+gdsid)
+		}
+		var change GoodsChange
+		err = unmarshalRecord(kindHistory, changeBytes, &change)
+		if err != nil {
+			return nil, newError(CodeCorruptRecord, "Error unmarshalling history of "+gdsid)
+		}
+		history = append(history, change)
+	}
+	return history, nil
+}
+
+// recordGoodsChange - appends an entry to the history of the goods, entries are never rewritten
+func recordGoodsChange(stub shim.ChaincodeStubInterface, action string, old *Goods, goods *Goods) error {
+	seqKey, err := createCompositeKey(historySeqObjectType, []string{goods.GDSID})
+	if err != nil {
+		return err
+	}
+	seqBytes, err := stub.GetState(seqKey)
+	if err != nil {
+		return newError(CodeLedger, "Error retrieving history sequence of "+goods.GDSID)
+	}
+	seq := 0
+	if seqBytes != nil {
+		seq, err = strconv.Atoi(string(seqBytes))
+		if err != nil {
+			return newError(CodeCorruptRecord, "Corrupt history sequence of "+goods.GDSID)
+		}
+	}
+	seq++
+
+	timestamp, err := txTimestamp(stub)
+	if err != nil {
+		return err
+	}
+	change := GoodsChange{
+		GDSID:     goods.GDSID,
+		Sequence:  seq,
+		Action:    action,
+		Actor:     callerName(stub),
+		TxID:      stub.GetTxID(),
+		Timestamp: timestamp,
+		Old:       old,
+		New:       goods,
+		SchemaVersion: schemaVersions[kindHistory],
+	}
+	changeBytes, err := json.Marshal(&change)
+	if err != nil {
+		return newError(CodeInternal, "Error marshalling history of "+goods.GDSID)
+	}
+
+	// zero padded so the entries sort in sequence order
+	key, err := createCompositeKey(historyObjectType, []string{goods.GDSID, fmt.Sprintf("%010d", seq)})
+	if err != nil {
+		return err
+	}
+	err = stub.PutState(key, changeBytes)
+	if err != nil {
+		return newError(CodeLedger, "Error writing history of "+goods.GDSID)
+	}
+	err = stub.PutState(seqKey, []byte(strconv.Itoa(seq)))
+	if err != nil {
+		return newError(CodeLedger, "Error writing history sequence of "+goods.GDSID)
+	}
+	return nil
+}
+
+// anonymous is the caller name of transactions without a certificate
+const anonymous = "anonymous"
+
+// callerName - common name of the certificate that signed the transaction, anonymous without one
+func callerName(stub shim.ChaincodeStubInterface) string {
+	certBytes, err := stub.GetCallerCertificate()
+	if err != nil || len(certBytes) == 0 {
+		return anonymous
+	}
+	if block, _ := pem.Decode(certBytes); block != nil {
+		certBytes = block.Bytes
+	}
+	cert, err := x509.ParseCertificate(certBytes)
+	if err != nil || cert.Subject.CommonName == "" {
+		return anonymous
+	}
+	return cert.Subject.CommonName
+}
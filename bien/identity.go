@@ -0,0 +1,235 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		 http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bien
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// The caller is known by the common name of the certificate that signed the transaction.
+// An admin can bind that name to a company and roles with bind_identity; names without a
+// binding take them from the company and roles attributes of the certificate. Only the roles
+// the registered company holds count, and none while it is suspended. The router
+// checks the roles a function is declared with, handlers check the records they touch.
+const (
+	RoleIssuer  = "issuer"
+	RoleBuyer   = "buyer"
+	RoleShipper = "shipper"
+	RoleAuditor = "auditor"
+	RoleAdmin   = "admin"		//passes every check
+)
+
+var knownRoles = []string{RoleIssuer, RoleBuyer, RoleShipper, RoleAuditor, RoleAdmin}
+
+// Identity is who a transaction is sent by, stored under identity~name when bound
+type Identity struct {
+	Name          string   `json:"name"`
+	Company       string   `json:"company"`
+	Roles         []string `json:"roles"`
+	SchemaVersion int      `json:"schemaVersion"`
+}
+
+func init() {
+	registerFunctions(
+		Function{Name: "bind_identity", Kind: KindInvoke, Description: "Binds a certificate common name to a company and roles",
+			Args: []ArgSpec{{Name: "identity", Type: ArgJSON, Description: "name, company and roles", Model: Identity{}}},
+			Errors: []string{CodeCompanyNotFound},
+			Roles: []string{RoleAdmin},
+			Handler: (*BienChaincode).bind_identity},
+		Function{Name: "whoami", Kind: KindQuery, Description: "The company and roles of the caller",
+			Returns: Identity{},
+			Handler: (*BienChaincode).whoami},
+	)
+}
+
+// bind_identity - admin invoke function fixing the company and roles of a caller
+func (t *BienChaincode) bind_identity(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+
+	/*		0
+		json
+		{
+			"name": "alice",			// common name of the certificate
+			"company": "company1",
+			"roles": ["issuer", "buyer"]
+		}
+	*/
+
+	var identity Identity
+	err := json.Unmarshal([]byte(args[0]), &identity)
+	if err != nil {
+		fmt.Println(err)
+		return nil, argError("identity", "Invalid identity")
+	}
+	if identity.Name == "" || identity.Name == anonymous {
+		return nil, argError("identity", "Identity needs the common name of a certificate")
+	}
+	for _, role := range identity.Roles {
+		if !isKnownRole(role) {
+			return nil, argError("identity", "Unknown role "+role)
+		}
+	}
+	if identity.Company == "" && !identity.hasRole(RoleAdmin, RoleAuditor) {
+		return nil, argError("identity", "Only admins and auditors may be bound without a company")
+	}
+	if identity.Company != "" {
+		_, err = getCompany(stub, identity.Company)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = putIdentity(stub, identity)
+	if err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// whoami - query function returning the identity the caller is checked as
+func (t *BienChaincode) whoami(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	caller, err := callerIdentity(stub)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(&caller)
+}
+
+// callerIdentity - the bound identity of the caller, or the one its certificate attributes describe.
+// Certificate attributes never make an admin, only a binding does: bind_identity or the deployer's.
+func callerIdentity(stub shim.ChaincodeStubInterface) (Identity, error) {
+	identity := Identity{Name: callerName(stub), Roles: []string{}}
+	if identity.Name == anonymous {
+		return identity, nil
+	}
+
+	key, err := createCompositeKey(identityObjectType, []string{identity.Name})
+	if err != nil {
+		return identity, err
+	}
+	identityBytes, err := stub.GetState(key)
+	if err != nil {
+		return identity, newError(CodeLedger, "Error retrieving identity of "+identity.Name)
+	}
+	if identityBytes != nil {
+		err = unmarshalRecord(kindIdentity, identityBytes, &identity)
+		if err != nil {
+			return identity, newError(CodeCorruptRecord, "Error unmarshalling identity of "+identity.Name)
+		}
+		return capRoles(stub, identity)
+	}
+
+	if company, err := stub.ReadCertAttribute("company"); err == nil {
+		identity.Company = string(company)
+	}
+	if roles, err := stub.ReadCertAttribute("roles"); err == nil {
+		for _, role := range strings.Split(string(roles), ",") {
+			role = strings.TrimSpace(role)
+			if isKnownRole(role) && role != RoleAdmin {
+				identity.Roles = append(identity.Roles, role)
+			}
+		}
+	}
+	return capRoles(stub, identity)
+}
+
+// capRoles - drops the roles of an identity its company does not hold, admin is not a company role
+// and stays with the identities bound as admin
+func capRoles(stub shim.ChaincodeStubInterface, identity Identity) (Identity, error) {
+	if identity.Company == "" {
+		return identity, nil
+	}
+	company, err := findCompany(stub, identity.Company)
+	if err != nil {
+		return identity, err
+	}
+	roles := []string{}
+	for _, role := range identity.Roles {
+		if role == RoleAdmin ||
+			(company != nil && company.Status == CompanyActive && containsString(company.Roles, role)) {
+			roles = append(roles, role)
+		}
+	}
+	identity.Roles = roles
+	return identity, nil
+}
+
+// putIdentity - writes the binding of a certificate common name
+func putIdentity(stub shim.ChaincodeStubInterface, identity Identity) error {
+	key, err := createCompositeKey(identityObjectType, []string{identity.Name})
+	if err != nil {
+		return err
+	}
+	identity.SchemaVersion = schemaVersions[kindIdentity]
+	identityBytes, err := json.Marshal(&identity)
+	if err != nil {
+		return newError(CodeInternal, "Error marshalling identity of "+identity.Name)
+	}
+	err = stub.PutState(key, identityBytes)
+	if err != nil {
+		return newError(CodeLedger, "Error writing identity of "+identity.Name)
+	}
+	return nil
+}
+
+// hasRole - true when the identity holds one of the roles, admins hold them all
+func (identity Identity) hasRole(roles ...string) bool {
+	for _, held := range identity.Roles {
+		if held == RoleAdmin {
+			return true
+		}
+		for _, role := range roles {
+			if held == role {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkRoles - the router's check of the roles a function is declared with, no roles lets everybody in
+func checkRoles(caller Identity, function string, roles []string) error {
+	if len(roles) == 0 || caller.hasRole(roles...) {
+		return nil
+	}
+	if caller.Name == anonymous {
+		return &Error{Code: CodeUnauthenticated, Message: function + " needs a signed transaction", Details: roles}
+	}
+	return &Error{Code: CodeForbidden, Message: caller.Name + " may not call " + function + ", it needs one of the roles " +
+		strings.Join(roles, ", "), Details: roles}
+}
+
+// requireCompany - fails unless the caller acts for the company, or is an admin
+func requireCompany(caller Identity, company string) error {
+	if caller.hasRole(RoleAdmin) || (caller.Company != "" && caller.Company == company) {
+		return nil
+	}
+	return newError(CodeForbidden, caller.Name+" does not act for "+company)
+}
+
+func isKnownRole(role string) bool {
+	return containsString(knownRoles, role)
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
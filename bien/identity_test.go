@@ -0,0 +1,121 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		 http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bien
+
+import (
+	"reflect"
+	"testing"
+)
+
+// whoami - the identity a caller is checked as
+func (l *testLedger) whoami(c testCaller) Identity {
+	l.t.Helper()
+	var identity Identity
+	l.decode(l.must(c, "whoami"), &identity)
+	return identity
+}
+
+var identityCases = register([]funcCase{
+	{name: "binds a name without attributes", fn: "bind_identity", as: asAdmin,
+		args: []string{`{"name":"erin","company":"company1","roles":["buyer"]}`},
+		check: func(l *testLedger, result []byte) {
+			erin := l.whoami(testCaller{name: "erin"})
+			if erin.Company != "company1" || !reflect.DeepEqual(erin.Roles, []string{"buyer"}) {
+				l.t.Errorf("erin is %+v", erin)
+			}
+		}},
+	{name: "overrides the certificate attributes", fn: "bind_identity", as: asAdmin,
+		args: []string{`{"name":"alice","company":"company2","roles":["shipper"]}`},
+		check: func(l *testLedger, result []byte) {
+			alice := l.whoami(asAlice)
+			if alice.Company != "company2" || !reflect.DeepEqual(alice.Roles, []string{"shipper"}) {
+				l.t.Errorf("alice is %+v", alice)
+			}
+		}},
+	{name: "binds admins", fn: "bind_identity", as: asAdmin,
+		args: []string{`{"name":"erin","roles":["admin"]}`},
+		check: func(l *testLedger, result []byte) {
+			l.must(testCaller{name: "erin"}, "deposit", "company1", "50")
+			checkBalance(l, "company1", 150)
+		}},
+	{name: "needs a registered company", fn: "bind_identity", as: asAdmin,
+		args: []string{`{"name":"erin","company":"ghost","roles":["buyer"]}`},
+		code: CodeCompanyNotFound},
+	{name: "needs known roles", fn: "bind_identity", as: asAdmin,
+		args: []string{`{"name":"erin","company":"company1","roles":["king"]}`},
+		code: CodeInvalidArgument},
+	{name: "needs a company for company roles", fn: "bind_identity", as: asAdmin,
+		args: []string{`{"name":"erin","roles":["buyer"]}`},
+		code: CodeInvalidArgument},
+	{name: "is for admins", fn: "bind_identity", as: asAlice,
+		args: []string{`{"name":"alice","company":"company1","roles":["admin"]}`},
+		code: CodeForbidden},
+
+	{name: "reads the certificate attributes", fn: "whoami", as: asAlice,
+		check: func(l *testLedger, result []byte) {
+			var alice Identity
+			l.decode(result, &alice)
+			want := Identity{Name: "alice", Company: "company1", Roles: []string{"issuer", "buyer"}}
+			if !reflect.DeepEqual(alice, want) {
+				l.t.Errorf("alice is %+v, want %+v", alice, want)
+			}
+		}},
+	{name: "drops unknown roles", fn: "whoami", as: testCaller{"mallory", "company4", "king, issuer"},
+		check: func(l *testLedger, result []byte) {
+			var mallory Identity
+			l.decode(result, &mallory)
+			if !reflect.DeepEqual(mallory.Roles, []string{"issuer"}) {
+				l.t.Errorf("mallory has the roles %v, want [issuer]", mallory.Roles)
+			}
+		}},
+	{name: "keeps the roles the company holds", fn: "whoami", as: testCaller{"mallory", "company4", "shipper,issuer"},
+		check: func(l *testLedger, result []byte) {
+			var mallory Identity
+			l.decode(result, &mallory)
+			if !reflect.DeepEqual(mallory.Roles, []string{"issuer"}) {
+				l.t.Errorf("mallory has the roles %v, want [issuer]", mallory.Roles)
+			}
+		}},
+	{name: "takes no admin from the certificate", fn: "whoami", as: testCaller{"mallory", "company4", "admin,issuer"},
+		check: func(l *testLedger, result []byte) {
+			var mallory Identity
+			l.decode(result, &mallory)
+			if !reflect.DeepEqual(mallory.Roles, []string{"issuer"}) {
+				l.t.Errorf("mallory has the roles %v, want [issuer]", mallory.Roles)
+			}
+		}},
+	{name: "leaves unbound admins powerless", fn: "deposit", as: testCaller{name: "mallory", roles: "admin"},
+		args: []string{"company1", "1000000"},
+		code: CodeForbidden},
+	{name: "answers unsigned callers", fn: "whoami", as: asAnonymous,
+		check: func(l *testLedger, result []byte) {
+			var nobody Identity
+			l.decode(result, &nobody)
+			if nobody.Name != anonymous || len(nobody.Roles) != 0 {
+				l.t.Errorf("an unsigned caller is %+v", nobody)
+			}
+		}},
+	{name: "makes the deployer admin", fn: "whoami", as: asAdmin,
+		check: func(l *testLedger, result []byte) {
+			var deployer Identity
+			l.decode(result, &deployer)
+			if !reflect.DeepEqual(deployer.Roles, []string{RoleAdmin}) {
+				l.t.Errorf("the deployer has the roles %v", deployer.Roles)
+			}
+		}},
+})
+
+func TestIdentityFunctions(t *testing.T) {
+	runCases(t, identityCases)
+}
@@ -0,0 +1,392 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		 http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bien
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Every goods record has its GDSID. An issuer can choose a GS1 scheme on top of it,
+// the goods then also get a GTIN or SSCC when issued, and partners' identifiers can be
+// registered later. Each identifier is cross referenced to the GDSID, so GetGD resolves
+// goods from any of them.
+const (
+	SchemeGDSID  = "gdsid"
+	SchemeGTIN13 = "gtin13"
+	SchemeGTIN14 = "gtin14"
+	SchemeSSCC18 = "sscc18"
+)
+
+// IDScheme is one family of goods identifiers
+type IDScheme interface {
+	// Validate checks the layout and check digit of an identifier
+	Validate(id string) error
+	// Generate builds the identifier of the seq-th goods numbered under the issuer's settings
+	Generate(settings IDSchemeSettings, seq int) (string, error)
+}
+
+// idSchemes are the schemes an issuer can choose from
+var idSchemes = map[string]IDScheme{
+	SchemeGDSID:  gdsidScheme{},
+	SchemeGTIN13: gs1Scheme{length: 13},
+	SchemeGTIN14: gs1Scheme{length: 14, leadDigit: true},
+	SchemeSSCC18: gs1Scheme{length: 18, leadDigit: true},
+}
+
+// IDSchemeSettings is the identifier scheme an issuer chose, stored under idscheme~issuer
+type IDSchemeSettings struct {
+	Issuer        string `json:"issuer"`
+	Scheme        string `json:"scheme"`
+	CompanyPrefix string `json:"companyPrefix"`		//GS1 company prefix, 6 to 12 digits
+	LeadDigit     string `json:"leadDigit"`			//GTIN-14 indicator or SSCC extension digit, 0 when not set
+	Reason        string `json:"reason,omitempty"`		//why the chaincode went back to GDSIDs only, see assignSchemeID
+	SchemaVersion int    `json:"schemaVersion"`
+}
+
+func init() {
+	registerFunctions(
+		Function{Name: "set_id_scheme", Kind: KindInvoke, Description: "Chooses the identifier scheme of an issuer's goods",
+			Args: []ArgSpec{{Name: "settings", Type: ArgJSON, Description: "issuer, scheme, companyPrefix and leadDigit", Model: IDSchemeSettings{}}},
+			Errors: []string{CodeCompanyNotFound, CodeCompanySuspended},
+			Roles: []string{RoleIssuer},
+			Handler: (*BienChaincode).set_id_scheme},
+		Function{Name: "get_id_scheme", Kind: KindQuery, Description: "The identifier scheme an issuer's goods get, gdsid when it chose none",
+			Args: []ArgSpec{{Name: "issuer", Type: ArgString}},
+			Returns: IDSchemeSettings{},
+			Handler: (*BienChaincode).get_id_scheme},
+		Function{Name: "register_goods_id", Kind: KindInvoke, Description: "Adds an identifier of another scheme to goods",
+			Args: []ArgSpec{{Name: "gdsid", Type: ArgString}, {Name: "scheme", Type: ArgString}, {Name: "id", Type: ArgString}},
+			Errors: []string{CodeGoodsNotFound, CodeInvalidIdentifier, CodeIdentifierTaken, CodeCompanyNotFound, CodeCompanySuspended},
+			Roles: []string{RoleIssuer},
+			Handler: (*BienChaincode).register_goods_id},
+	)
+}
+
+// set_id_scheme - invoke function choosing the identifier scheme of the goods an issuer issues from now on
+func (t *BienChaincode) set_id_scheme(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+
+	/*		0
+		json
+		{
+			"issuer": "company2",
+			"scheme": "gtin13",
+			"companyPrefix": "4006381",
+			"leadDigit": "1"			// gtin14 and sscc18 only
+		}
+	*/
+
+	var settings IDSchemeSettings
+	err := json.Unmarshal([]byte(args[0]), &settings)
+	if err != nil {
+		fmt.Println(err)
+		return nil, argError("settings", "Invalid id scheme settings")
+	}
+	if settings.Issuer == "" {
+		return nil, argError("settings", "Id scheme settings need an issuer")
+	}
+	caller, err := callerIdentity(stub)
+	if err != nil {
+		return nil, err
+	}
+	err = requireCompany(caller, settings.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	err = checkCompanies(stub, settings.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := idSchemes[settings.Scheme]; !ok {
+		return nil, argError("settings", "Unknown id scheme "+settings.Scheme)
+	}
+	if settings.Scheme != SchemeGDSID {
+		if !isDigits(settings.CompanyPrefix) || len(settings.CompanyPrefix) < 6 || len(settings.CompanyPrefix) > 12 {
+			return nil, argError("settings", "GS1 company prefix must be 6 to 12 digits")
+		}
+		if settings.LeadDigit == "" {
+			settings.LeadDigit = "0"
+		}
+		if len(settings.LeadDigit) != 1 || !isDigits(settings.LeadDigit) {
+			return nil, argError("settings", "Lead digit must be a single digit")
+		}
+		if idSchemes[settings.Scheme].(gs1Scheme).referenceDigits(settings) < 1 {
+			return nil, argError("settings", "Company prefix "+settings.CompanyPrefix+" leaves no digits for the reference numbers of "+settings.Scheme)
+		}
+	}
+	settings.Reason = ""
+	return nil, putIDScheme(stub, settings)
+}
+
+// get_id_scheme - query function returning the identifier scheme of an issuer
+func (t *BienChaincode) get_id_scheme(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	settings, err := getIDScheme(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+	if settings == nil {
+		settings = &IDSchemeSettings{Issuer: args[0], Scheme: SchemeGDSID, SchemaVersion: schemaVersions[kindIDScheme]}
+	}
+	return json.Marshal(settings)
+}
+
+// getIDScheme - the identifier scheme an issuer chose, nil when it chose none
+func getIDScheme(stub shim.ChaincodeStubInterface, issuer string) (*IDSchemeSettings, error) {
+	key, err := createCompositeKey(idSchemeObjectType, []string{issuer})
+	if err != nil {
+		return nil, err
+	}
+	settingsBytes, err := stub.GetState(key)
+	if err != nil {
+		return nil, newError(CodeLedger, "Error retrieving id scheme of "+issuer)
+	}
+	if settingsBytes == nil {
+		return nil, nil
+	}
+	var settings IDSchemeSettings
+	err = unmarshalRecord(kindIDScheme, settingsBytes, &settings)
+	if err != nil {
+		return nil, newError(CodeCorruptRecord, "Error unmarshalling id scheme of "+issuer)
+	}
+	return &settings, nil
+}
+
+// putIDScheme - writes the identifier scheme of an issuer
+func putIDScheme(stub shim.ChaincodeStubInterface, settings IDSchemeSettings) error {
+	key, err := createCompositeKey(idSchemeObjectType, []string{settings.Issuer})
+	if err != nil {
+		return err
+	}
+	settings.SchemaVersion = schemaVersions[kindIDScheme]
+	settingsBytes, err := json.Marshal(&settings)
+	if err != nil {
+		return newError(CodeInternal, "Error marshalling id scheme settings")
+	}
+	err = stub.PutState(key, settingsBytes)
+	if err != nil {
+		return newError(CodeLedger, "Error writing id scheme of "+settings.Issuer)
+	}
+	return nil
+}
+
+// register_goods_id - invoke function adding an identifier of another scheme to a goods record
+func (t *BienChaincode) register_goods_id(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	//   0       1        2
+	// GDSID  scheme  identifier
+	if args[1] == SchemeGDSID {
+		return nil, argError("scheme", "The GDSID of goods cannot be registered again")
+	}
+
+	goods, err := getGoods(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+	caller, err := callerIdentity(stub)
+	if err != nil {
+		return nil, err
+	}
+	err = requireCompany(caller, goods.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	err = checkCompanies(stub, goods.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	old := map[string]string{}
+	for scheme, id := range goods.Identifiers {
+		old[scheme] = id
+	}
+	err = registerGoodsID(stub, &goods, args[1], args[2])
+	if err != nil {
+		return nil, err
+	}
+	err = putGoods(stub, goods, "register_id")
+	if err != nil {
+		return nil, err
+	}
+	return nil, emitGoodsEvent(stub, EventGoodsIDRegistered, GoodsEvent{GDSID: goods.GDSID, Old: old, New: goods.Identifiers})
+}
+
+// assignSchemeID - gives newly issued goods an identifier of the issuer's scheme, if it chose one besides the GDSID
+func assignSchemeID(stub shim.ChaincodeStubInterface, goods *Goods) error {
+	settings, err := getIDScheme(stub, goods.Issuer)
+	if err != nil {
+		return err
+	}
+	if settings == nil || settings.Scheme == SchemeGDSID {
+		return nil
+	}
+
+	seq, err := nextGoodsSequence(stub, settings.Scheme+":"+settings.CompanyPrefix)
+	if err != nil {
+		return err
+	}
+	id, err := idSchemes[settings.Scheme].Generate(*settings, seq)
+	if err != nil && asError(err).Code == CodeIdentifiersExhausted {
+		// issuing goes on with GDSIDs only, get_id_scheme tells the issuer why until it sets a new company prefix
+		logger.Warningf("%s, %s goes back to GDSIDs only", asError(err).Message, goods.Issuer)
+		settings.Scheme = SchemeGDSID
+		settings.Reason = asError(err).Message
+		return putIDScheme(stub, *settings)
+	}
+	if err != nil {
+		return err
+	}
+	return registerGoodsID(stub, goods, settings.Scheme, id)
+}
+
+// registerGoodsID - validates the identifier and cross references it to the goods
+func registerGoodsID(stub shim.ChaincodeStubInterface, goods *Goods, scheme string, id string) error {
+	idScheme, ok := idSchemes[scheme]
+	if !ok {
+		return argError("scheme", "Unknown id scheme "+scheme)
+	}
+	err := idScheme.Validate(id)
+	if err != nil {
+		return err
+	}
+
+	xrefKey, err := createCompositeKey(goodsIDObjectType, []string{scheme, id})
+	if err != nil {
+		return err
+	}
+	existing, err := stub.GetState(xrefKey)
+	if err != nil {
+		return newError(CodeLedger, "Error retrieving "+scheme+" "+id)
+	}
+	if existing != nil {
+		return newError(CodeIdentifierTaken, scheme+" "+id+" is already registered to goods "+string(existing))
+	}
+	err = stub.PutState(xrefKey, []byte(goods.GDSID))
+	if err != nil {
+		return newError(CodeLedger, "Error writing "+scheme+" "+id)
+	}
+
+	if goods.Identifiers == nil {
+		goods.Identifiers = map[string]string{}
+	}
+	goods.Identifiers[scheme] = id
+	return nil
+}
+
+// resolveGDSID - the GDSID of the goods an identifier of any scheme was registered to.
+// Ids of no scheme, or GTINs and SSCCs nobody registered, come back as they are: goods
+// migrated or imported from Bien keep ids like company2B3 and are found by their locator.
+// A GDSID with a wrong check digit is mistyped and fails before any goods are read.
+func resolveGDSID(stub shim.ChaincodeStubInterface, id string) (string, error) {
+	if _, err := parseGDSID(id); err == nil {
+		return id, nil
+	} else if hasGDSIDLayout(id) {
+		return "", err
+	}
+	for _, scheme := range []string{SchemeGTIN13, SchemeGTIN14, SchemeSSCC18} {
+		if idSchemes[scheme].Validate(id) != nil {
+			continue
+		}
+		xrefKey, err := createCompositeKey(goodsIDObjectType, []string{scheme, id})
+		if err != nil {
+			return "", err
+		}
+		gdsid, err := stub.GetState(xrefKey)
+		if err != nil {
+			return "", newError(CodeLedger, "Error retrieving "+scheme+" "+id)
+		}
+		if gdsid != nil {
+			return string(gdsid), nil
+		}
+	}
+	return id, nil
+}
+
+// gdsidScheme is the chaincode's own CUSIP-like identifier, assigned at issue
+type gdsidScheme struct{}
+
+func (gdsidScheme) Validate(id string) error {
+	_, err := parseGDSID(id)
+	return err
+}
+
+func (gdsidScheme) Generate(settings IDSchemeSettings, seq int) (string, error) {
+	return "", newError(CodeInternal, "GDSIDs are only assigned when goods are issued")
+}
+
+// gs1Scheme covers GTIN-13, GTIN-14 and SSCC-18: an optional lead digit, the company
+// prefix, a reference filling up the remaining digits and the GS1 check digit
+type gs1Scheme struct {
+	length    int
+	leadDigit bool
+}
+
+func (s gs1Scheme) Validate(id string) error {
+	if len(id) != s.length || !isDigits(id) {
+		return newError(CodeInvalidIdentifier, id+" must be "+strconv.Itoa(s.length)+" digits")
+	}
+	if gs1CheckDigit(id[:s.length-1]) != id[s.length-1:] {
+		return newError(CodeInvalidIdentifier, "Check digit of "+id+" does not match, the ID is mistyped")
+	}
+	return nil
+}
+
+func (s gs1Scheme) Generate(settings IDSchemeSettings, seq int) (string, error) {
+	body := settings.CompanyPrefix
+	if s.leadDigit {
+		body = settings.LeadDigit + body
+	}
+	refDigits := s.referenceDigits(settings)
+	if refDigits <= 0 {
+		return "", newError(CodeInvalidArgument, "Company prefix "+settings.CompanyPrefix+" is too long for "+settings.Scheme)
+	}
+	ref := fmt.Sprintf("%0*d", refDigits, seq)
+	if len(ref) > refDigits {
+		return "", newError(CodeIdentifiersExhausted, "Company prefix "+settings.CompanyPrefix+" has run out of "+settings.Scheme+" numbers")
+	}
+	body += ref
+	return body + gs1CheckDigit(body), nil
+}
+
+// referenceDigits - the digits the company prefix leaves for numbering goods
+func (s gs1Scheme) referenceDigits(settings IDSchemeSettings) int {
+	refDigits := s.length - 1 - len(settings.CompanyPrefix)
+	if s.leadDigit {
+		refDigits--
+	}
+	return refDigits
+}
+
+// gs1CheckDigit - GS1 modulo 10 check digit, weights 3 and 1 alternate from the rightmost digit
+func gs1CheckDigit(body string) string {
+	sum := 0
+	weight := 3
+	for i := len(body) - 1; i >= 0; i-- {
+		sum += int(body[i]-'0') * weight
+		weight = 4 - weight
+	}
+	return strconv.Itoa((10 - sum%10) % 10)
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
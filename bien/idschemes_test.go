@@ -0,0 +1,152 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		 http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bien
+
+import (
+	"reflect"
+	"testing"
+)
+
+// idScheme - the scheme get_id_scheme returns for an issuer
+func (l *testLedger) idScheme(issuer string) IDSchemeSettings {
+	l.t.Helper()
+	var settings IDSchemeSettings
+	l.decode(l.must(asAnonymous, "get_id_scheme", issuer), &settings)
+	return settings
+}
+
+// addSecond - company2 issues a second goods record, its GDSID becomes {second}
+func addSecond(l *testLedger) {
+	l.vars["second"] = string(l.must(asCarol, "add_goods", `{"name":"table","price":40,"issuer":"company2","quantity":1}`))
+}
+
+var idSchemeCases = register([]funcCase{
+	{name: "chooses a GS1 scheme", fn: "set_id_scheme", as: asCarol,
+		args: []string{`{"issuer":"company2","scheme":"gtin14","companyPrefix":"4006381","leadDigit":"1"}`},
+		check: func(l *testLedger, result []byte) {
+			want := IDSchemeSettings{Issuer: "company2", Scheme: SchemeGTIN14, CompanyPrefix: "4006381", LeadDigit: "1",
+				SchemaVersion: schemaVersions[kindIDScheme]}
+			if settings := l.idScheme("company2"); !reflect.DeepEqual(settings, want) {
+				l.t.Errorf("id scheme of company2 is %+v, want %+v", settings, want)
+			}
+		}},
+	{name: "needs an issuer", fn: "set_id_scheme", as: asCarol,
+		args: []string{`{"scheme":"gtin13","companyPrefix":"4006381"}`},
+		code: CodeInvalidArgument},
+	{name: "needs a registered issuer", fn: "set_id_scheme", as: asAdmin,
+		args: []string{`{"issuer":"ghost","scheme":"gtin13","companyPrefix":"4006381"}`},
+		code: CodeCompanyNotFound},
+	{name: "needs an active issuer", fn: "set_id_scheme", as: asAdmin,
+		args: []string{`{"issuer":"company3","scheme":"gtin13","companyPrefix":"4006381"}`},
+		code: CodeCompanySuspended},
+	{name: "needs a GS1 company prefix", fn: "set_id_scheme", as: asCarol,
+		args: []string{`{"issuer":"company2","scheme":"gtin13","companyPrefix":"40063"}`},
+		code: CodeInvalidArgument},
+	{name: "needs digits left for reference numbers", fn: "set_id_scheme", as: asCarol,
+		args: []string{`{"issuer":"company2","scheme":"gtin13","companyPrefix":"400638133393"}`},
+		code: CodeInvalidArgument},
+	{name: "needs a known scheme", fn: "set_id_scheme", as: asCarol,
+		args: []string{`{"issuer":"company2","scheme":"ean8","companyPrefix":"4006381"}`},
+		code: CodeInvalidArgument},
+
+	{name: "defaults to GDSIDs", fn: "get_id_scheme", as: asAnonymous,
+		args: []string{"company1"},
+		check: func(l *testLedger, result []byte) {
+			var settings IDSchemeSettings
+			l.decode(result, &settings)
+			if settings.Issuer != "company1" || settings.Scheme != SchemeGDSID {
+				l.t.Errorf("id scheme of company1 is %+v", settings)
+			}
+		}},
+
+	{name: "adds a partner's GTIN", fn: "register_goods_id", as: asCarol,
+		args: []string{"{gdsid}", "gtin13", "4006381333931"},
+		event: EventGoodsIDRegistered,
+		check: func(l *testLedger, result []byte) {
+			goods := l.goods("4006381333931")
+			if goods.GDSID != l.vars["gdsid"] || goods.Identifiers[SchemeGTIN13] != "4006381333931" {
+				l.t.Errorf("GTIN 4006381333931 finds %+v", goods)
+			}
+		}},
+	{name: "needs existing goods", fn: "register_goods_id", as: asCarol,
+		args: []string{"nothere", "gtin13", "4006381333931"},
+		code: CodeGoodsNotFound},
+	{name: "checks the check digit", fn: "register_goods_id", as: asCarol,
+		args: []string{"{gdsid}", "gtin13", "4006381333932"},
+		code: CodeInvalidIdentifier},
+	{name: "refuses a GTIN registered to other goods", fn: "register_goods_id", as: asCarol,
+		setup: func(l *testLedger) {
+			addSecond(l)
+			l.must(asCarol, "register_goods_id", "{second}", "gtin13", "4006381333931")
+		},
+		args: []string{"{gdsid}", "gtin13", "4006381333931"},
+		code: CodeIdentifierTaken},
+	{name: "needs a registered issuer", fn: "register_goods_id", as: asAdmin,
+		setup: putOrphanGoods,
+		args: []string{"orphan1", "gtin13", "4006381333931"},
+		code: CodeCompanyNotFound},
+	{name: "needs an active issuer", fn: "register_goods_id", as: asAdmin,
+		setup: suspend("company2"),
+		args: []string{"{gdsid}", "gtin13", "4006381333931"},
+		code: CodeCompanySuspended},
+	{name: "does not register a second GDSID", fn: "register_goods_id", as: asCarol,
+		setup: addSecond,
+		args: []string{"{gdsid}", "gdsid", "{second}"},
+		code: CodeInvalidArgument},
+})
+
+func TestIDSchemeFunctions(t *testing.T) {
+	runCases(t, idSchemeCases)
+}
+
+func TestGS1CheckDigit(t *testing.T) {
+	for id, scheme := range map[string]string{
+		"4006381333931":      SchemeGTIN13,
+		"10614141000415":     SchemeGTIN14,
+		"106141411234567897": SchemeSSCC18,
+	} {
+		if got := gs1CheckDigit(id[:len(id)-1]); got != id[len(id)-1:] {
+			t.Errorf("gs1CheckDigit(%s) = %s", id[:len(id)-1], got)
+		}
+		if err := idSchemes[scheme].Validate(id); err != nil {
+			t.Errorf("%s rejected %s: %v", scheme, id, err)
+		}
+	}
+	for id, scheme := range map[string]string{
+		"4006381333932":  SchemeGTIN13,	//check digit
+		"400638133393":   SchemeGTIN13,	//length
+		"1061414100041A": SchemeGTIN14,
+	} {
+		if err := idSchemes[scheme].Validate(id); err == nil {
+			t.Errorf("%s accepted %s", scheme, id)
+		}
+	}
+}
+
+func TestGS1Generate(t *testing.T) {
+	gtin13 := IDSchemeSettings{Issuer: "company2", Scheme: SchemeGTIN13, CompanyPrefix: "4006381"}
+	id, err := idSchemes[SchemeGTIN13].Generate(gtin13, 42)
+	if err != nil || id != "4006381000420" {
+		t.Errorf("generated %q, %v, want 4006381000420", id, err)
+	}
+	if _, err := idSchemes[SchemeGTIN13].Generate(gtin13, 100000); err == nil {
+		t.Error("generated a GTIN-13 past the last reference of the prefix")
+	}
+
+	sscc := IDSchemeSettings{Issuer: "company2", Scheme: SchemeSSCC18, CompanyPrefix: "0614141", LeadDigit: "1"}
+	id, err = idSchemes[SchemeSSCC18].Generate(sscc, 123456789)
+	if err != nil || id != "106141411234567897" {
+		t.Errorf("generated %q, %v, want 106141411234567897", id, err)
+	}
+}
@@ -0,0 +1,92 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		 http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bien
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Composite keys are an object type followed by attributes, each terminated by compositeKeySeparator.
+// Records of one type sort together, so a range scan over a key prefix replaces any index key.
+const (
+	compositeKeySeparator = "\x00"
+	maxUnicodeRune        = string(utf8.MaxRune)
+)
+
+// Object types of the composite keys
+const (
+	goodsObjectType = "goods"		// goods~issuer~gdsid -> Goods
+	gdsidObjectType = "gdsid"		// gdsid~gdsid -> issuer, locates the goods record of a GDSID
+	historyObjectType    = "history"		// history~gdsid~sequence -> GoodsChange
+	historySeqObjectType = "historyseq"	// historyseq~gdsid -> last history sequence of the goods
+	gdsidSeqObjectType   = "gdsidseq"		// gdsidseq~prefix -> last goods sequence of the issuer prefix
+	idSchemeObjectType   = "idscheme"		// idscheme~issuer -> IDSchemeSettings
+	goodsIDObjectType    = "goodsid"		// goodsid~scheme~identifier -> gdsid
+	identityObjectType   = "identity"		// identity~name -> Identity bound to a certificate common name
+	companyObjectType    = "company"		// company~id -> Company
+	shortCodeObjectType  = "shortcode"		// shortcode~code -> id of the company the GDSID issuer prefix belongs to
+	kvObjectType         = "kv"			// kv~key -> KVEntry written with write
+)
+
+// createCompositeKey - builds the key of an object from its type and attributes
+func createCompositeKey(objectType string, attributes []string) (string, error) {
+	if err := validateKeyPart(objectType); err != nil {
+		return "", err
+	}
+	key := objectType + compositeKeySeparator
+	for _, attribute := range attributes {
+		if err := validateKeyPart(attribute); err != nil {
+			return "", err
+		}
+		key += attribute + compositeKeySeparator
+	}
+	return key, nil
+}
+
+// splitCompositeKey - returns the object type and attributes of a composite key
+func splitCompositeKey(key string) (string, []string, error) {
+	parts := strings.Split(key, compositeKeySeparator)
+	if len(parts) < 2 || parts[len(parts)-1] != "" {
+		return "", nil, newError(CodeCorruptRecord, "Not a composite key: "+key)
+	}
+	return parts[0], parts[1 : len(parts)-1], nil
+}
+
+// compositeKeyRange - start and end key of a range scan over every key starting with the partial key
+func compositeKeyRange(objectType string, attributes []string) (string, string, error) {
+	startKey, err := createCompositeKey(objectType, attributes)
+	if err != nil {
+		return "", "", err
+	}
+	return startKey, startKey + maxUnicodeRune, nil
+}
+
+// goodsKey - composite key of a goods record
+func goodsKey(issuer string, gdsid string) (string, error) {
+	if issuer == "" || gdsid == "" {
+		return "", newError(CodeInvalidArgument, "Goods key needs an issuer and a gdsid")
+	}
+	return createCompositeKey(goodsObjectType, []string{issuer, gdsid})
+}
+
+func validateKeyPart(part string) error {
+	if !utf8.ValidString(part) {
+		return newError(CodeInvalidArgument, "Key part "+part+" is not valid UTF-8")
+	}
+	if strings.Contains(part, compositeKeySeparator) || strings.Contains(part, maxUnicodeRune) {
+		return newError(CodeInvalidArgument, "Key part "+part+" contains a reserved character")
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		 http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bien
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompositeKeys(t *testing.T) {
+	key, err := goodsKey("company2", "g1")
+	if err != nil || key != "goods\x00company2\x00g1\x00" {
+		t.Fatalf("goodsKey returned %q, %v", key, err)
+	}
+	objectType, attributes, err := splitCompositeKey(key)
+	if err != nil || objectType != goodsObjectType || !reflect.DeepEqual(attributes, []string{"company2", "g1"}) {
+		t.Errorf("splitCompositeKey(%q) = %q, %q, %v", key, objectType, attributes, err)
+	}
+
+	startKey, endKey, err := compositeKeyRange(goodsObjectType, []string{"company2"})
+	if err != nil || !(startKey <= key && key < endKey) {
+		t.Errorf("range %q to %q does not hold %q", startKey, endKey, key)
+	}
+	other, _ := goodsKey("company20", "g1")
+	if startKey <= other && other < endKey {
+		t.Errorf("range of company2 holds %q", other)
+	}
+}
+
+func TestCompositeKeysRejectReservedCharacters(t *testing.T) {
+	for _, part := range []string{"a\x00b", "a" + maxUnicodeRune, "\xff"} {
+		if _, err := createCompositeKey(goodsObjectType, []string{part}); err == nil {
+			t.Errorf("created a key with %q", part)
+		}
+	}
+	if _, err := goodsKey("", "g1"); err == nil {
+		t.Error("created a goods key without an issuer")
+	}
+	if _, _, err := splitCompositeKey("goods:g1"); err == nil {
+		t.Error("split goods:g1")
+	}
+}
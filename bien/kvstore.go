@@ -0,0 +1,239 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		 http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bien
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// write, read and delete keep user key/value pairs under kv~key, apart from every record
+// of the domain functions, so they cannot reach goods, accounts or trades. A key belongs to
+// whoever wrote it first. Keys starting with one of the reserved prefixes are refused even
+// there, so an old client writing goods:<id> gets an error instead of a record nobody reads.
+// Admins recovering the ledger use read_raw, write_raw and delete_raw on the real keys.
+
+// reservedKeyPrefixes are the raw keys of the domain functions, "_" covers _opentrades
+// and any index key to come
+var reservedKeyPrefixes = []string{goodsPrefix, transferPrefix, accountPrefix, "_"}
+
+// KVEntry is a user key/value pair, stored under kv~key
+type KVEntry struct {
+	Key           string `json:"key"`
+	Value         string `json:"value"`
+	Owner         string `json:"owner"`		//common name of the certificate that wrote the key first
+	Company       string `json:"company,omitempty"`
+	SchemaVersion int    `json:"schemaVersion"`
+}
+
+func init() {
+	userRoles := []string{RoleIssuer, RoleBuyer, RoleShipper, RoleAuditor}
+	registerFunctions(
+		Function{Name: "write", Kind: KindInvoke, Description: "Writes a user key/value pair, only its owner overwrites it",
+			Args: []ArgSpec{{Name: "key", Type: ArgString}, {Name: "value", Type: ArgString, AllowEmpty: true}},
+			Errors: []string{CodeReservedKey},
+			Roles: userRoles,
+			Handler: (*BienChaincode).write},
+		Function{Name: "delete", Kind: KindInvoke, Description: "Deletes a user key/value pair, by its owner",
+			Args: []ArgSpec{{Name: "key", Type: ArgString}},
+			Errors: []string{CodeKeyNotFound, CodeReservedKey},
+			Roles: userRoles,
+			Handler: (*BienChaincode).delete},
+		Function{Name: "read", Kind: KindQuery, Description: "Reads the value of a user key, nothing when it was never written",
+			Args: []ArgSpec{{Name: "key", Type: ArgString}},
+			Returns: "",
+			Errors: []string{CodeReservedKey},
+			Handler: (*BienChaincode).read},
+		Function{Name: "read_raw", Kind: KindQuery, Description: "Reads any key of the ledger as it is stored, for recovery",
+			Args: []ArgSpec{{Name: "key", Type: ArgString}},
+			Returns: "",
+			Roles: []string{RoleAdmin},
+			Handler: (*BienChaincode).read_raw},
+		Function{Name: "write_raw", Kind: KindInvoke, Description: "Writes any key of the ledger bypassing every check, for recovery",
+			Args: []ArgSpec{{Name: "key", Type: ArgString}, {Name: "value", Type: ArgString, AllowEmpty: true}},
+			Roles: []string{RoleAdmin},
+			Handler: (*BienChaincode).write_raw},
+		Function{Name: "delete_raw", Kind: KindInvoke, Description: "Deletes any key of the ledger bypassing every check, for recovery",
+			Args: []ArgSpec{{Name: "key", Type: ArgString}},
+			Roles: []string{RoleAdmin},
+			Handler: (*BienChaincode).delete_raw},
+	)
+}
+
+// write - invoke function to write a user key/value pair
+func (t *BienChaincode) write(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	fmt.Println("running write()")
+
+	caller, err := callerIdentity(stub)
+	if err != nil {
+		return nil, err
+	}
+	entry, err := findEntry(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		entry = &KVEntry{Key: args[0], Owner: caller.Name, Company: caller.Company}
+	} else {
+		err = requireKeyOwner(caller, *entry)
+		if err != nil {
+			return nil, err
+		}
+	}
+	entry.Value = args[1]
+	return nil, putEntry(stub, *entry)
+}
+
+// delete - invoke function to delete a user key/value pair
+func (t *BienChaincode) delete(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	caller, err := callerIdentity(stub)
+	if err != nil {
+		return nil, err
+	}
+	entry, err := findEntry(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, &Error{Code: CodeKeyNotFound, Message: "Key " + args[0] + " does not exist", Arg: "key"}
+	}
+	err = requireKeyOwner(caller, *entry)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := createCompositeKey(kvObjectType, []string{entry.Key})
+	if err != nil {
+		return nil, err
+	}
+	err = stub.DelState(key)
+	if err != nil {
+		return nil, newError(CodeLedger, "Failed to delete state for "+entry.Key)
+	}
+	return nil, nil
+}
+
+// read - query function to read the value of a user key
+func (t *BienChaincode) read(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	entry, err := findEntry(stub, args[0])
+	logger.Infof("query.read logger entry=%v", entry)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+	return []byte(entry.Value), nil
+}
+
+// read_raw - admin query function reading a key of the ledger as it is
+func (t *BienChaincode) read_raw(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	valAsbytes, err := stub.GetState(args[0])
+	if err != nil {
+		return nil, newError(CodeLedger, "Failed to get state for "+args[0])
+	}
+	return valAsbytes, nil
+}
+
+// write_raw - admin invoke function writing a key of the ledger, nothing checks what is written
+func (t *BienChaincode) write_raw(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	fmt.Println("Raw write of " + args[0])
+	err := stub.PutState(args[0], []byte(args[1]))
+	if err != nil {
+		return nil, newError(CodeLedger, "Failed to put state for "+args[0])
+	}
+	return nil, nil
+}
+
+// delete_raw - admin invoke function deleting a key of the ledger, nothing checks what refers to it
+func (t *BienChaincode) delete_raw(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	fmt.Println("Raw delete of " + args[0])
+	err := stub.DelState(args[0])
+	if err != nil {
+		return nil, newError(CodeLedger, "Failed to delete state for "+args[0])
+	}
+	return nil, nil
+}
+
+// findEntry - reads a user key/value pair, nil when the key was never written
+func findEntry(stub shim.ChaincodeStubInterface, userKey string) (*KVEntry, error) {
+	err := checkUserKey(userKey)
+	if err != nil {
+		return nil, err
+	}
+	key, err := createCompositeKey(kvObjectType, []string{userKey})
+	if err != nil {
+		return nil, err
+	}
+	entryBytes, err := stub.GetState(key)
+	if err != nil {
+		return nil, newError(CodeLedger, "Failed to get state for "+userKey)
+	}
+	if entryBytes == nil {
+		return nil, nil
+	}
+	var entry KVEntry
+	err = unmarshalRecord(kindKV, entryBytes, &entry)
+	if err != nil {
+		return nil, newError(CodeCorruptRecord, "Error unmarshalling key "+userKey)
+	}
+	return &entry, nil
+}
+
+// putEntry - writes a user key/value pair
+func putEntry(stub shim.ChaincodeStubInterface, entry KVEntry) error {
+	err := checkUserKey(entry.Key)
+	if err != nil {
+		return err
+	}
+	key, err := createCompositeKey(kvObjectType, []string{entry.Key})
+	if err != nil {
+		return err
+	}
+	entry.SchemaVersion = schemaVersions[kindKV]
+	entryBytes, err := json.Marshal(&entry)
+	if err != nil {
+		return newError(CodeInternal, "Error marshalling key "+entry.Key)
+	}
+	err = stub.PutState(key, entryBytes)
+	if err != nil {
+		return newError(CodeLedger, "Failed to put state for "+entry.Key)
+	}
+	return nil
+}
+
+// checkUserKey - fails on keys the user namespace does not take
+func checkUserKey(key string) error {
+	if key == "" {
+		return argError("key", "Key must be a non-empty string")
+	}
+	for _, prefix := range reservedKeyPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return &Error{Code: CodeReservedKey, Arg: "key",
+				Message: "Keys starting with " + prefix + " are kept by the chaincode, " + key + " cannot be used"}
+		}
+	}
+	return nil
+}
+
+// requireKeyOwner - fails unless the caller wrote the key first, or is an admin
+func requireKeyOwner(caller Identity, entry KVEntry) error {
+	if caller.hasRole(RoleAdmin) || (caller.Name != anonymous && caller.Name == entry.Owner) {
+		return nil
+	}
+	return newError(CodeForbidden, "Key "+entry.Key+" belongs to "+entry.Owner)
+}
@@ -0,0 +1,113 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		 http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bien
+
+import (
+	"testing"
+)
+
+// aliceWrites - alice writes color=red first, the key is hers
+func aliceWrites(l *testLedger) {
+	l.must(asAlice, "write", "color", "red")
+}
+
+func checkValue(l *testLedger, key string, want string) {
+	l.t.Helper()
+	if value := l.must(asAnonymous, "read", key); string(value) != want {
+		l.t.Errorf("%s is %q, want %q", key, value, want)
+	}
+}
+
+var kvCases = register([]funcCase{
+	{name: "writes a new key", fn: "write", as: asAlice,
+		args: []string{"color", "red"},
+		check: func(l *testLedger, result []byte) {
+			checkValue(l, "color", "red")
+		}},
+	{name: "lets the owner overwrite", fn: "write", as: asAlice,
+		setup: aliceWrites,
+		args: []string{"color", "blue"},
+		check: func(l *testLedger, result []byte) {
+			checkValue(l, "color", "blue")
+		}},
+	{name: "lets admins overwrite", fn: "write", as: asAdmin,
+		setup: aliceWrites,
+		args: []string{"color", ""},
+		check: func(l *testLedger, result []byte) {
+			checkValue(l, "color", "")
+		}},
+	{name: "keeps the goods apart", fn: "write", as: asAlice,
+		args: []string{"goods:x", "{}"},
+		code: CodeReservedKey},
+	{name: "keeps other callers' keys", fn: "write", as: asBob,
+		setup: aliceWrites,
+		args: []string{"color", "green"},
+		code: CodeForbidden},
+
+	{name: "deletes the owner's key", fn: "delete", as: asAlice,
+		setup: aliceWrites,
+		args: []string{"color"},
+		check: func(l *testLedger, result []byte) {
+			checkValue(l, "color", "")
+			l.mustFail(CodeKeyNotFound, asAlice, "delete", "color")
+		}},
+	{name: "needs a written key", fn: "delete", as: asAlice,
+		args: []string{"color"},
+		code: CodeKeyNotFound},
+	{name: "keeps the order book apart", fn: "delete", as: asAlice,
+		args: []string{"_opentrades"},
+		code: CodeReservedKey},
+	{name: "keeps other callers' keys", fn: "delete", as: asBob,
+		setup: aliceWrites,
+		args: []string{"color"},
+		code: CodeForbidden},
+
+	{name: "reads for anybody", fn: "read", as: asAnonymous,
+		setup: aliceWrites,
+		args: []string{"color"},
+		check: func(l *testLedger, result []byte) {
+			if string(result) != "red" {
+				l.t.Errorf("read returned %q", result)
+			}
+		}},
+	{name: "keeps the accounts apart", fn: "read", as: asAnonymous,
+		args: []string{"acct:company1"},
+		code: CodeReservedKey},
+
+	{name: "reads any key as stored", fn: "read_raw", as: asAdmin,
+		args: []string{"acct:company1"},
+		check: func(l *testLedger, result []byte) {
+			var account Account
+			l.decode(result, &account)
+			if account.Company != "company1" {
+				l.t.Errorf("read_raw returned %s", result)
+			}
+		}},
+	{name: "writes any key", fn: "write_raw", as: asAdmin,
+		args: []string{"_opentrades", `{"open_trades":[],"schemaVersion":1}`},
+		check: func(l *testLedger, result []byte) {
+			if value := l.must(asAdmin, "read_raw", "_opentrades"); string(value) != `{"open_trades":[],"schemaVersion":1}` {
+				l.t.Errorf("_opentrades is %s", value)
+			}
+		}},
+	{name: "deletes any key", fn: "delete_raw", as: asAdmin,
+		args: []string{"acct:company1"},
+		check: func(l *testLedger, result []byte) {
+			l.mustFail(CodeAccountNotFound, asAdmin, "get_account", "company1")
+		}},
+})
+
+func TestKVFunctions(t *testing.T) {
+	runCases(t, kvCases)
+}
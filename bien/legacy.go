@@ -0,0 +1,158 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		 http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bien
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// The chaincode_new and chaincode-back variants kept a Bien per key, with a single
+// owner and integer prices. Their functions live on here over the Goods model, so
+// clients written against them keep working: add_goods with five arguments issues
+// goods, set_owner hands all units to one company, and change_state takes Bien ids.
+// A Bien still stored under its own key is moved to the goods keys the first time
+// one of these functions touches it.
+
+func init() {
+	registerFunctions(
+		Function{Name: "set_owner", Kind: KindInvoke, Description: "Moves every unit of goods to one company",
+			Args: []ArgSpec{{Name: "gdsid", Type: ArgString}, {Name: "company", Type: ArgString}},
+			Returns: Goods{},
+			Errors: []string{CodeGoodsNotFound, CodeGoodsInactive, CodeCompanyNotFound, CodeCompanySuspended},
+			Roles: []string{RoleAdmin},
+			Handler: (*BienChaincode).set_owner},
+	)
+}
+
+// add_bien - the add_goods of the Bien variants, issues goods owned by the given company
+func (t *BienChaincode) add_bien(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	//   0       1       2          3       4
+	// "name", "owner", "state", "price"  "postage"
+
+	fmt.Println("- start add goods")
+	names := []string{"name", "owner", "state", "price", "postage"}
+	for i, arg := range args {
+		if len(arg) <= 0 {
+			return nil, argError(names[i], "Argument "+strconv.Itoa(i+1)+" must be a non-empty string")
+		}
+	}
+	price, err := strconv.ParseFloat(args[3], 64)
+	if err != nil {
+		return nil, argError("price", "4th argument must be a numeric price")
+	}
+	postage, err := strconv.ParseFloat(args[4], 64)
+	if err != nil {
+		return nil, argError("postage", "5th argument must be a numeric postage")
+	}
+
+	goods := Goods{Name: args[0], Issuer: args[1], State: args[2], Price: price, Postage: postage, Quantity: 1}
+	goodsBytes, err := json.Marshal(&goods)
+	if err != nil {
+		return nil, newError(CodeInternal, "Error marshalling goods")
+	}
+	return t.issueCommercialGoods(stub, []string{string(goodsBytes)})
+}
+
+// set_owner - invoke function of the Bien variants, moves every unit of the goods to one company
+func (t *BienChaincode) set_owner(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	//   0       1
+	// GDSID  company
+	if args[1] == "" {
+		return nil, argError("company", "New owner must be a non-empty string")
+	}
+	err := checkCompanies(stub, args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Println("- start set owner-")
+	fmt.Println(args[0] + " - " + args[1])
+	goods, err := getLegacyGoods(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+	if isTerminalState(currentState(goods)) {
+		return nil, newError(CodeGoodsInactive, "Goods "+goods.GDSID+" is "+currentState(goods)+" and cannot change owner")
+	}
+
+	old := copyOwners(goods)
+	var transfers []*Transaction
+	companies := []string{args[1]}
+	for _, owner := range append([]Owner(nil), goods.Owners...) {
+		if owner.Company == args[1] {
+			continue
+		}
+		moved, err := moveQuantity(&goods, owner.Company, args[1], 0)
+		if err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, &Transaction{GDSID: goods.GDSID, FromCompany: owner.Company,
+			ToCompany: args[1], Quantity: moved})
+		companies = append(companies, owner.Company)
+	}
+	if len(goods.Owners) == 0 {
+		// goods nobody held go to the new owner whole
+		goods.Owners = []Owner{{Company: args[1], Quantity: goods.Quantity}}
+	}
+
+	err = putGoods(stub, goods, "set_owner")
+	if err != nil {
+		return nil, err
+	}
+	for _, tr := range transfers {
+		err = recordTransfer(stub, tr)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = syncAssets(stub, goods, companies...)
+	if err != nil {
+		return nil, err
+	}
+	err = emitGoodsEvent(stub, EventGoodsOwnerSet, GoodsEvent{GDSID: goods.GDSID, Old: old, New: goods.Owners})
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Println("- end set owner-")
+	return json.Marshal(&goods)
+}
+
+// getLegacyGoods - getGoods that also accepts the id of a Bien still stored under its own key,
+// moving that record to the goods keys first. Only invokes may use it, it writes.
+func getLegacyGoods(stub shim.ChaincodeStubInterface, id string) (Goods, error) {
+	exists, err := goodsExists(stub, id)
+	if err != nil {
+		return Goods{}, err
+	}
+	if !exists {
+		bienBytes, err := stub.GetState(id)
+		if err != nil {
+			return Goods{}, newError(CodeLedger, "Error retrieving goods "+id)
+		}
+		if bienBytes != nil {
+			if version, err := recordVersion(kindGoods, bienBytes); err == nil && version == 0 {
+				err = importLegacyBien(stub, id)
+				if err != nil {
+					return Goods{}, err
+				}
+			}
+		}
+	}
+	return getGoods(stub, id)
+}
@@ -0,0 +1,104 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		 http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bien
+
+import (
+	"testing"
+)
+
+// a Bien of chaincode_new, stored under its own id
+const bienRecord = `{"orderId":"1479891234","name":"vase","state":"shipped","price":5,"postage":1,"owner":"company2"}`
+
+// putBien - a Bien of chaincode_new stored under its order id
+func putBien(l *testLedger) {
+	l.put("1479891234", bienRecord)
+}
+
+// importedGoods - the goods record the Bien was imported as, GetGD does not take Bien ids
+func importedGoods(l *testLedger) Goods {
+	l.t.Helper()
+	if _, ok := l.stub.State["1479891234"]; ok {
+		l.t.Error("the Bien is still stored under its order id")
+	}
+	goods := l.goods("1479891234")
+	if goods.Name != "vase" || goods.Issuer != "company2" || goods.Quantity != 1 {
+		l.t.Errorf("imported %+v", goods)
+	}
+	return goods
+}
+
+var legacyCases = register([]funcCase{
+	{name: "moves every unit to one company", fn: "set_owner", as: asAdmin,
+		setup: func(l *testLedger) {
+			l.must(asCarol, "transfer_goods", `{"gdsid":"{gdsid}","fromCompany":"company2","toCompany":"company4","quantity":3}`)
+		},
+		args: []string{"{gdsid}", "company1"},
+		event: EventGoodsOwnerSet,
+		check: func(l *testLedger, result []byte) {
+			var goods Goods
+			l.decode(result, &goods)
+			if goods.GDSID != l.vars["gdsid"] {
+				l.t.Errorf("set_owner returned %s", result)
+			}
+			checkOwners(l, l.vars["gdsid"], owners(Owner{"company1", 10}))
+			if assets := l.account("company1").AssetsIds; len(assets) != 1 || assets[0] != l.vars["gdsid"] {
+				l.t.Errorf("assets of company1 are %v", assets)
+			}
+			if assets := l.account("company2").AssetsIds; len(assets) != 0 {
+				l.t.Errorf("assets of company2 are %v", assets)
+			}
+		}},
+	{name: "imports a Bien stored under its id", fn: "set_owner", as: asAdmin,
+		setup: putBien,
+		args: []string{"1479891234", "company1"},
+		event: EventGoodsOwnerSet,
+		check: func(l *testLedger, result []byte) {
+			if goods := importedGoods(l); len(goods.Owners) != 1 || goods.Owners[0] != (Owner{"company1", 1}) {
+				l.t.Errorf("owners of the Bien are %+v", goods.Owners)
+			}
+		}},
+	{name: "needs existing goods", fn: "set_owner", as: asAdmin,
+		args: []string{"nothere", "company1"},
+		code: CodeGoodsNotFound},
+	{name: "does not move cancelled goods", fn: "set_owner", as: asAdmin,
+		setup: cancelGoods,
+		args: []string{"{gdsid}", "company1"},
+		code: CodeGoodsInactive},
+	{name: "needs a registered owner", fn: "set_owner", as: asAdmin,
+		args: []string{"{gdsid}", "ghost"},
+		code: CodeCompanyNotFound},
+	{name: "needs an active owner", fn: "set_owner", as: asAdmin,
+		args: []string{"{gdsid}", "company3"},
+		code: CodeCompanySuspended},
+	{name: "is for admins", fn: "set_owner", as: asCarol,
+		args: []string{"{gdsid}", "company1"},
+		code: CodeForbidden},
+	{name: "needs an owner", fn: "set_owner", as: asAdmin,
+		args: []string{"{gdsid}", ""},
+		code: CodeInvalidArgument},
+
+	{name: "imports a Bien stored under its id", fn: "change_state", as: asCarol,
+		setup: putBien,
+		args: []string{"1479891234", "delivered"},
+		event: EventGoodsStateChanged,
+		check: func(l *testLedger, result []byte) {
+			if goods := importedGoods(l); goods.State != StateDelivered {
+				l.t.Errorf("state of the Bien is %s", goods.State)
+			}
+		}},
+})
+
+func TestLegacyFunctions(t *testing.T) {
+	runCases(t, legacyCases)
+}
@@ -0,0 +1,172 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		 http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bien
+
+import (
+	"errors"
+	"strings"
+)
+
+// Lifecycle states a goods record moves through
+const (
+	StateNew       = "new"
+	StateListed    = "listed"
+	StateOrdered   = "ordered"
+	StatePaid      = "paid"
+	StateShipped   = "shipped"
+	StateDelivered = "delivered"
+	StateClosed    = "closed"
+	StateCancelled = "cancelled"
+	StateReturned  = "returned"
+)
+
+// stateTransitions lists, for every state, the states it may move to next.
+// closed and cancelled are terminal.
+var stateTransitions = map[string][]string{
+	StateNew:       {StateListed, StateCancelled},
+	StateListed:    {StateOrdered, StateCancelled},
+	StateOrdered:   {StatePaid, StateCancelled},
+	StatePaid:      {StateShipped, StateCancelled},
+	StateShipped:   {StateDelivered, StateReturned},
+	StateDelivered: {StateClosed, StateReturned},
+	StateReturned:  {StateListed, StateClosed},
+	StateClosed:    {},
+	StateCancelled: {},
+}
+
+// statePreconditions are extra checks the goods must pass before entering a state
+var statePreconditions = map[string]func(goods Goods) error{
+	StateListed:  requirePrice,
+	StateShipped: requireOwners,
+}
+
+// stateError - a lifecycle error of goods that cannot move to the requested state
+func stateError(code string, goods Goods, from string, to string, allowed []string, message string) *Error {
+	return &Error{Code: code, Message: message,
+		Details: StateDetails{GDSID: goods.GDSID, From: from, To: to, Allowed: allowed}}
+}
+
+// normalizeState - lower cases and trims a client supplied state, "" if it is not a known state
+func normalizeState(state string) string {
+	state = strings.ToLower(strings.TrimSpace(state))
+	if _, ok := stateTransitions[state]; !ok {
+		return ""
+	}
+	return state
+}
+
+// legacyStates are free text states goods were given before the lifecycle, by the lifecycle state they stand for
+var legacyStates = map[string]string{
+	"canceled":   StateCancelled,
+	"open":       StateListed,
+	"for sale":   StateListed,
+	"sold":       StatePaid,
+	"in transit": StateShipped,
+	"transit":    StateShipped,
+	"received":   StateDelivered,
+	"complete":   StateClosed,
+	"completed":  StateClosed,
+	"done":       StateClosed,
+	"return":     StateReturned,
+}
+
+// mapLegacyState - the lifecycle state a free text state stands for, "" when it stands for none.
+// Case and trailing digits (shipped2) are ignored.
+func mapLegacyState(state string) string {
+	state = strings.TrimSpace(strings.TrimRight(strings.ToLower(strings.TrimSpace(state)), "0123456789"))
+	if mapped := normalizeState(state); mapped != "" {
+		return mapped
+	}
+	return legacyStates[state]
+}
+
+// currentState - state of a goods record, records written before the lifecycle existed count as new
+func currentState(goods Goods) string {
+	if goods.State == "" {
+		return StateNew
+	}
+	return goods.State
+}
+
+// isTerminalState - true when no transition leaves the state
+func isTerminalState(state string) bool {
+	return len(stateTransitions[state]) == 0
+}
+
+// checkTransition - validates moving goods to the requested state and returns the normalized target
+func checkTransition(goods Goods, requested string) (string, error) {
+	from := currentState(goods)
+	allowed := stateTransitions[from]
+
+	to := normalizeState(requested)
+	if to == "" {
+		return "", stateError(CodeInvalidState, goods, from, requested, allowed, "Unknown state "+requested)
+	}
+
+	legal := false
+	for _, next := range allowed {
+		if next == to {
+			legal = true
+			break
+		}
+	}
+	if !legal {
+		return "", stateError(CodeInvalidStateTransition, goods, from, to, allowed,
+			"Goods cannot move from "+from+" to "+to)
+	}
+
+	if precondition, ok := statePreconditions[to]; ok {
+		if err := precondition(goods); err != nil {
+			return "", stateError(CodeStatePreconditionFailed, goods, from, to, allowed, err.Error())
+		}
+	}
+	return to, nil
+}
+
+// checkStateChange - the issuer or a company owning every unit moves goods through the lifecycle.
+// Delivery is reported by the company that ordered the goods instead, and callers that only hold
+// the shipper role report nothing but shipping and delivery.
+func checkStateChange(caller Identity, goods Goods, to string) error {
+	if caller.hasRole(RoleAdmin) {
+		return nil
+	}
+	if to == StateDelivered && goods.OrderedBy != "" {
+		if requireCompany(caller, goods.OrderedBy) != nil {
+			return newError(CodeForbidden, "Only "+goods.OrderedBy+", which ordered goods "+goods.GDSID+", reports them delivered")
+		}
+		return nil
+	}
+	total := totalOwned(goods)
+	if requireCompany(caller, goods.Issuer) != nil && (caller.Company == "" || total == 0 || ownedBy(goods, caller.Company) < total) {
+		return newError(CodeForbidden, caller.Name+" neither issued nor owns every unit of goods "+goods.GDSID)
+	}
+	if !caller.hasRole(RoleIssuer) && !caller.hasRole(RoleBuyer) && to != StateShipped && to != StateDelivered {
+		return newError(CodeForbidden, caller.Name+" only reports goods shipped or delivered")
+	}
+	return nil
+}
+
+func requirePrice(goods Goods) error {
+	if goods.Price <= 0 {
+		return errors.New("Goods " + goods.GDSID + " needs a price before it is listed")
+	}
+	return requireOwners(goods)
+}
+
+func requireOwners(goods Goods) error {
+	if len(goods.Owners) == 0 {
+		return errors.New("Goods " + goods.GDSID + " has no owner")
+	}
+	return nil
+}
@@ -0,0 +1,242 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		 http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bien
+
+import (
+	"testing"
+)
+
+// sellTo - company2 transfers units of {gdsid} to another company
+func sellTo(company string, quantity string) func(l *testLedger) {
+	return func(l *testLedger) {
+		l.must(asCarol, "transfer_goods", `{"gdsid":"{gdsid}","fromCompany":"company2","toCompany":"`+company+`","quantity":`+quantity+`}`)
+	}
+}
+
+// paidAndSold - {gdsid} is ordered by company1 and paid for, company1 holds 4 of the 10 units
+func paidAndSold(l *testLedger) {
+	l.walk(StateListed, StateOrdered, StatePaid)
+	sellTo("company1", "4")(l)
+}
+
+// soldWhole - company1 holds every unit of {gdsid}
+func soldWhole(l *testLedger) {
+	l.must(asAdmin, "set_owner", "{gdsid}", "company1")
+}
+
+// shipped - {gdsid} is ordered by company1 and on its way
+func shipped(l *testLedger) {
+	l.walk(StateListed, StateOrdered, StatePaid, StateShipped)
+}
+
+// checkOrderedBy - fails the test unless the goods record the company that ordered them
+func checkOrderedBy(l *testLedger, id string, want string) {
+	l.t.Helper()
+	if got := l.goods(id).OrderedBy; got != want {
+		l.t.Errorf("goods %s are ordered by %q, want %q", id, got, want)
+	}
+}
+
+var lifecycleCases = register([]funcCase{
+	{name: "shippers report the goods their company owns whole shipped", fn: "change_state", as: asSam,
+		setup: func(l *testLedger) {
+			l.walk(StateListed, StateOrdered, StatePaid)
+			soldWhole(l)
+		},
+		args: []string{"{gdsid}", "shipped"},
+		event: EventGoodsStateChanged,
+		check: func(l *testLedger, result []byte) {
+			checkState(l, l.vars["gdsid"], StateShipped)
+		}},
+	{name: "shippers of the issuer report its goods shipped", fn: "change_state", as: asSid,
+		setup: paidAndSold,
+		args: []string{"{gdsid}", "shipped"},
+		event: EventGoodsStateChanged},
+	{name: "shippers only ship goods their company owns whole", fn: "change_state", as: asSam,
+		setup: paidAndSold,
+		args: []string{"{gdsid}", "shipped"},
+		code: CodeForbidden},
+	{name: "shippers only report shipping and delivery", fn: "change_state", as: asSid,
+		setup: paidAndSold,
+		args: []string{"{gdsid}", "cancelled"},
+		code: CodeForbidden},
+	{name: "records the company ordering the goods", fn: "change_state", as: asCarol,
+		setup: func(l *testLedger) {
+			l.walk(StateListed)
+		},
+		args: []string{"{gdsid}", "ordered", "company1"},
+		event: EventGoodsStateChanged,
+		check: func(l *testLedger, result []byte) {
+			checkOrderedBy(l, l.vars["gdsid"], "company1")
+		}},
+	{name: "needs the company ordering the goods", fn: "change_state", as: asCarol,
+		setup: func(l *testLedger) {
+			l.walk(StateListed)
+		},
+		args: []string{"{gdsid}", "ordered"},
+		code: CodeInvalidArgument},
+	{name: "takes the ordering company only with ordered", fn: "change_state", as: asCarol,
+		args: []string{"{gdsid}", "listed", "company1"},
+		code: CodeInvalidArgument},
+	{name: "needs a registered ordering company", fn: "change_state", as: asCarol,
+		setup: func(l *testLedger) {
+			l.walk(StateListed)
+		},
+		args: []string{"{gdsid}", "ordered", "ghost"},
+		code: CodeCompanyNotFound},
+	{name: "needs an active ordering company", fn: "change_state", as: asCarol,
+		setup: func(l *testLedger) {
+			l.walk(StateListed)
+		},
+		args: []string{"{gdsid}", "ordered", "company3"},
+		code: CodeCompanySuspended},
+	{name: "the ordering company reports delivery", fn: "change_state", as: asBob,
+		setup: shipped,
+		args: []string{"{gdsid}", "delivered"},
+		event: EventGoodsStateChanged},
+	{name: "shippers of the ordering company report delivery", fn: "change_state", as: asSam,
+		setup: shipped,
+		args: []string{"{gdsid}", "delivered"},
+		event: EventGoodsStateChanged},
+	{name: "the seller does not report delivery of ordered goods", fn: "change_state", as: asCarol,
+		setup: shipped,
+		args: []string{"{gdsid}", "delivered"},
+		code: CodeForbidden},
+	{name: "relisting forgets the order", fn: "change_state", as: asCarol,
+		setup: func(l *testLedger) {
+			shipped(l)
+			l.walk(StateReturned)
+		},
+		args: []string{"{gdsid}", "listed"},
+		event: EventGoodsStateChanged,
+		check: func(l *testLedger, result []byte) {
+			checkOrderedBy(l, l.vars["gdsid"], "")
+		}},
+	{name: "owners of some units do not move the goods", fn: "change_state", as: asBob,
+		setup: sellTo("company1", "3"),
+		args: []string{"{gdsid}", "listed"},
+		code: CodeForbidden},
+	{name: "the owner of every unit moves the goods on", fn: "change_state", as: asAlice,
+		setup: soldWhole,
+		args: []string{"{gdsid}", "listed"},
+		event: EventGoodsStateChanged},
+	{name: "owners of some units do not cancel", fn: "change_state", as: asAlice,
+		setup: sellTo("company1", "4"),
+		args: []string{"{gdsid}", "cancelled"},
+		code: CodeForbidden},
+	{name: "the owner of every unit cancels", fn: "change_state", as: asAlice,
+		setup: soldWhole,
+		args: []string{"{gdsid}", "cancelled"},
+		event: EventGoodsStateChanged},
+	{name: "the issuer cancels goods it sold some of", fn: "change_state", as: asCarol,
+		setup: sellTo("company1", "4"),
+		args: []string{"{gdsid}", "cancelled"},
+		event: EventGoodsStateChanged},
+	{name: "the issuer cancels goods it sold whole", fn: "change_state", as: asCarol,
+		setup: soldWhole,
+		args: []string{"{gdsid}", "cancelled"},
+		event: EventGoodsStateChanged},
+	{name: "nothing leaves cancelled", fn: "change_state", as: asCarol,
+		setup: cancelGoods,
+		args: []string{"{gdsid}", "listed"},
+		code: CodeInvalidStateTransition},
+	{name: "admins move any goods", fn: "change_state", as: asAdmin,
+		args: []string{"{gdsid}", "listed"},
+		event: EventGoodsStateChanged},
+})
+
+func TestLifecycle(t *testing.T) {
+	runCases(t, lifecycleCases)
+}
+
+func TestCheckTransition(t *testing.T) {
+	owned := Goods{GDSID: "g1", Price: 12.5, Owners: []Owner{{Company: "company1"}}}
+	for _, c := range []struct {
+		goods     Goods
+		requested string
+		want      string
+		code      string
+	}{
+		{owned, "listed", StateListed, ""},
+		{owned, " Cancelled ", StateCancelled, ""},
+		{Goods{GDSID: "g1", Owners: owned.Owners, State: StateShipped}, "returned", StateReturned, ""},
+		{Goods{GDSID: "g1", Price: 12.5, Owners: owned.Owners, State: StateReturned}, "listed", StateListed, ""},
+		{owned, "sold", "", CodeInvalidState},
+		{owned, "paid", "", CodeInvalidStateTransition},
+		{Goods{GDSID: "g1", Owners: owned.Owners, State: StateClosed}, "listed", "", CodeInvalidStateTransition},
+		{Goods{GDSID: "g1", Price: 12.5}, "listed", "", CodeStatePreconditionFailed},
+		{Goods{GDSID: "g1", Owners: owned.Owners}, "listed", "", CodeStatePreconditionFailed},
+	} {
+		to, err := checkTransition(c.goods, c.requested)
+		if c.code == "" {
+			if err != nil || to != c.want {
+				t.Errorf("%s to %q returned %q, %v, want %q", currentState(c.goods), c.requested, to, err, c.want)
+			}
+			continue
+		}
+		details, ok := ParseError(err).Details.(StateDetails)
+		if errorCode(err) != c.code || !ok || details.GDSID != "g1" {
+			t.Errorf("%s to %q returned %v, want %s", currentState(c.goods), c.requested, err, c.code)
+		}
+	}
+}
+
+func TestTerminalStates(t *testing.T) {
+	for state := range stateTransitions {
+		terminal := state == StateClosed || state == StateCancelled
+		if isTerminalState(state) != terminal {
+			t.Errorf("isTerminalState(%s) = %v", state, !terminal)
+		}
+	}
+}
+
+// TestLifecycleWalk takes goods through every state from new to closed
+func TestLifecycleWalk(t *testing.T) {
+	l := newFixture(t)
+	l.walk(StateListed, StateOrdered, StatePaid, StateShipped, StateDelivered, StateReturned, StateListed,
+		StateOrdered, StatePaid, StateShipped, StateDelivered, StateClosed)
+	checkState(l, l.vars["gdsid"], StateClosed)
+	if _, err := l.call(asCarol, "change_state", "{gdsid}", StateListed); err == nil {
+		t.Error("closed goods were listed again")
+	}
+
+	var history []GoodsChange
+	l.decode(l.must(asAnonymous, "get_goods_history", "{gdsid}"), &history)
+	if len(history) != 13 {
+		t.Fatalf("history has %d entries, want the issue and 12 state changes", len(history))
+	}
+	for i, change := range history[1:] {
+		if change.Action != "change_state" || change.Sequence != i+2 || change.Old.State != history[i].New.State {
+			t.Errorf("change %d is %+v", i+2, change)
+		}
+	}
+}
+
+func TestMapLegacyState(t *testing.T) {
+	for state, want := range map[string]string{
+		"shipped2":   StateShipped,
+		" Listed ":   StateListed,
+		"CANCELED":   StateCancelled,
+		"for sale":   StateListed,
+		"In Transit": StateShipped,
+		"received1":  StateDelivered,
+		"completed":  StateClosed,
+		"wibble":     "",
+		"":           "",
+	} {
+		if got := mapLegacyState(state); got != want {
+			t.Errorf("mapLegacyState(%q) = %q, want %q", state, got, want)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		 http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bien
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// GoodsQuery selects one page of goods for list_goods, every filter is optional
+type GoodsQuery struct {
+	PageSize int      `json:"pageSize"`
+	Bookmark string   `json:"bookmark"`
+	Issuer   string   `json:"issuer"`
+	Owner    string   `json:"owner"`
+	State    string   `json:"state"`
+	MinPrice *float64 `json:"minPrice"`
+	MaxPrice *float64 `json:"maxPrice"`
+}
+
+// GoodsPage is one page of list_goods results, pass Bookmark back to get the next page
+type GoodsPage struct {
+	Items    []Goods `json:"items"`
+	Bookmark string  `json:"bookmark"`
+	HasMore  bool    `json:"hasMore"`
+}
+
+func init() {
+	registerFunctions(
+		Function{Name: "list_goods", Kind: KindQuery, Description: "One page of goods matching a filter",
+			Args: []ArgSpec{{Name: "query", Type: ArgJSON, Optional: true, Description: "pageSize, bookmark and filters", Model: GoodsQuery{}}},
+			Returns: GoodsPage{},
+			Handler: (*BienChaincode).list_goods},
+	)
+}
+
+// list_goods - query function returning one page of goods matching the filters
+func (t *BienChaincode) list_goods(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+
+	/*		0
+		json
+		{
+			"pageSize": 20,
+			"bookmark": "",			// bookmark of the previous page
+			"issuer": "company2",
+			"owner": "company1",
+			"state": "listed",
+			"minPrice": 1.00,
+			"maxPrice": 50.00
+		}
+	*/
+
+	var query GoodsQuery
+	if len(args) == 1 && args[0] != "" {
+		err := json.Unmarshal([]byte(args[0]), &query)
+		if err != nil {
+			fmt.Println(err)
+			return nil, argError("query", "Invalid goods query")
+		}
+	}
+
+	page, err := listGoods(stub, query)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(&page)
+}
+
+// listGoods - scans the goods after the bookmark until a page of matches is found
+func listGoods(stub shim.ChaincodeStubInterface, query GoodsQuery) (GoodsPage, error) {
+	var page GoodsPage
+
+	if query.PageSize <= 0 {
+		query.PageSize = defaultPageSize
+	}
+	if query.PageSize > maxPageSize {
+		query.PageSize = maxPageSize
+	}
+	if query.State != "" {
+		state := normalizeState(query.State)
+		if state == "" {
+			return page, argError("query", "Unknown state "+query.State)
+		}
+		query.State = state
+	}
+
+	// Filtering on the issuer narrows the scan to that issuer's part of the goods keys
+	var prefix []string
+	if query.Issuer != "" {
+		prefix = []string{query.Issuer}
+	}
+	startKey, endKey, err := compositeKeyRange(goodsObjectType, prefix)
+	if err != nil {
+		return page, err
+	}
+	// records not yet moved off their goods:<id> keys are listed after the others
+	legacyStart := goodsPrefix
+	if query.Bookmark != "" {
+		lastKey, err := base64.URLEncoding.DecodeString(query.Bookmark)
+		switch {
+		case err != nil:
+			return page, argError("query", "Invalid bookmark")
+		case strings.HasPrefix(string(lastKey), startKey):
+			// the smallest key sorting after the last key of the previous page
+			startKey = string(lastKey) + compositeKeySeparator
+		case strings.HasPrefix(string(lastKey), goodsPrefix):
+			startKey = ""
+			legacyStart = string(lastKey) + compositeKeySeparator
+		default:
+			return page, argError("query", "Invalid bookmark")
+		}
+	}
+
+	page.Items = []Goods{}
+	if startKey != "" {
+		err = scanGoodsPage(stub, startKey, endKey, query, &page, func(key string, gdBytes []byte) (Goods, error) {
+			var gd Goods
+			err := unmarshalRecord(kindGoods, gdBytes, &gd)
+			return gd, err
+		})
+		if err != nil {
+			return page, err
+		}
+	}
+	if !page.HasMore {
+		err = scanGoodsPage(stub, legacyStart, goodsPrefix+maxUnicodeRune, query, &page, func(key string, gdBytes []byte) (Goods, error) {
+			var gd Goods
+			if err := unmarshalRecord(kindGoods, gdBytes, &gd); err != nil {
+				return gd, err
+			}
+			if gd.GDSID == "" {
+				gd.GDSID = strings.TrimPrefix(key, goodsPrefix)
+			}
+			if query.Issuer != "" && gd.Issuer != query.Issuer {
+				return gd, errSkipGoods
+			}
+			return gd, nil
+		})
+		if err != nil {
+			return page, err
+		}
+	}
+	if !page.HasMore {
+		page.Bookmark = ""
+	}
+	return page, nil
+}
+
+// errSkipGoods - returned by the decoder of scanGoodsPage for records the scan passes over
+var errSkipGoods = errors.New("skip goods")
+
+// scanGoodsPage - adds the matching goods between the keys to the page until it is full
+func scanGoodsPage(stub shim.ChaincodeStubInterface, startKey string, endKey string, query GoodsQuery, page *GoodsPage,
+	decode func(key string, gdBytes []byte) (Goods, error)) error {
+	iter, err := stub.RangeQueryState(startKey, endKey)
+	if err != nil {
+		fmt.Println("Error scanning goods")
+		return newError(CodeLedger, "Error scanning goods")
+	}
+	defer iter.Close()
+
+	for iter.HasNext() {
+		key, gdBytes, err := iter.Next()
+		if err != nil {
+			return newError(CodeLedger, "Error scanning goods")
+		}
+
+		gd, err := decode(key, gdBytes)
+		if err == errSkipGoods {
+			continue
+		}
+		if err != nil {
+			return newError(CodeCorruptRecord, "Error unmarshalling goods "+key)
+		}
+		if !query.matches(gd) {
+			continue
+		}
+		if len(page.Items) == query.PageSize {
+			page.HasMore = true
+			break
+		}
+		page.Items = append(page.Items, gd)
+		page.Bookmark = base64.URLEncoding.EncodeToString([]byte(key))
+	}
+	return nil
+}
+
+// matches - true when the goods pass every filter of the query
+func (query GoodsQuery) matches(gd Goods) bool {
+	if query.Owner != "" && ownedBy(gd, query.Owner) <= 0 {
+		return false
+	}
+	if query.State != "" && currentState(gd) != query.State {
+		return false
+	}
+	if query.MinPrice != nil && gd.Price < *query.MinPrice {
+		return false
+	}
+	if query.MaxPrice != nil && gd.Price > *query.MaxPrice {
+		return false
+	}
+	return true
+}
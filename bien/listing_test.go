@@ -0,0 +1,130 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		 http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bien
+
+import (
+	"testing"
+)
+
+func goodsPage(l *testLedger, result []byte) GoodsPage {
+	l.t.Helper()
+	var page GoodsPage
+	l.decode(result, &page)
+	return page
+}
+
+var listingCases = register([]funcCase{
+	{name: "pages through the goods", fn: "list_goods", as: asAnonymous,
+		setup: addSecond,
+		args: []string{`{"pageSize":1}`},
+		check: func(l *testLedger, result []byte) {
+			first := goodsPage(l, result)
+			if len(first.Items) != 1 || !first.HasMore || first.Bookmark == "" {
+				l.t.Fatalf("first page is %+v", first)
+			}
+			second := goodsPage(l, l.must(asAnonymous, "list_goods", `{"pageSize":1,"bookmark":"`+first.Bookmark+`"}`))
+			if len(second.Items) != 1 || second.HasMore || second.Items[0].GDSID == first.Items[0].GDSID {
+				l.t.Errorf("second page is %+v after %+v", second, first)
+			}
+		}},
+	{name: "filters the goods", fn: "list_goods", as: asAnonymous,
+		setup: func(l *testLedger) {
+			addSecond(l)
+			l.walk(StateListed)
+		},
+		args: []string{`{"state":"listed","maxPrice":20}`},
+		check: func(l *testLedger, result []byte) {
+			if page := goodsPage(l, result); len(page.Items) != 1 || page.Items[0].GDSID != l.vars["gdsid"] {
+				l.t.Errorf("listed goods up to 20 are %+v", page)
+			}
+		}},
+	{name: "pages on into goods still stored under goods: keys", fn: "list_goods", as: asAnonymous,
+		setup: putLegacyGoods,
+		args: []string{`{"pageSize":1}`},
+		check: func(l *testLedger, result []byte) {
+			first := goodsPage(l, result)
+			if len(first.Items) != 1 || first.Items[0].GDSID != l.vars["gdsid"] || !first.HasMore {
+				l.t.Fatalf("first page is %+v", first)
+			}
+			second := goodsPage(l, l.must(asAnonymous, "list_goods", `{"pageSize":1,"bookmark":"`+first.Bookmark+`"}`))
+			if len(second.Items) != 1 || second.Items[0].GDSID != "legacy1" || second.HasMore || second.Bookmark != "" {
+				l.t.Errorf("second page is %+v", second)
+			}
+		}},
+	{name: "filters goods still stored under goods: keys", fn: "list_goods", as: asAnonymous,
+		setup: putLegacyGoods,
+		args: []string{`{"issuer":"company2","state":"listed"}`},
+		check: func(l *testLedger, result []byte) {
+			if page := goodsPage(l, result); len(page.Items) != 1 || page.Items[0].GDSID != "legacy1" {
+				l.t.Errorf("listed goods of company2 are %+v", page)
+			}
+			if page := goodsPage(l, l.must(asAnonymous, "list_goods", `{"issuer":"company1"}`)); len(page.Items) != 0 {
+				l.t.Errorf("goods of company1 are %+v", page)
+			}
+		}},
+	{name: "needs a known state", fn: "list_goods", as: asAnonymous,
+		args: []string{`{"state":"lost"}`},
+		code: CodeInvalidArgument},
+	{name: "needs a bookmark of an earlier page", fn: "list_goods", as: asAnonymous,
+		args: []string{`{"bookmark":"%%%"}`},
+		code: CodeInvalidArgument},
+
+	{name: "lists the changes oldest first", fn: "get_goods_history", as: asAnonymous,
+		setup: func(l *testLedger) {
+			l.must(asCarol, "transfer_goods", `{"gdsid":"{gdsid}","fromCompany":"company2","toCompany":"company1","quantity":4}`)
+		},
+		args: []string{"{gdsid}"},
+		check: func(l *testLedger, result []byte) {
+			var history []GoodsChange
+			l.decode(result, &history)
+			if len(history) != 2 || history[0].Action != "issue" || history[0].Old != nil ||
+				history[1].Action != "transfer" || history[1].Actor != "carol" || len(history[1].New.Owners) != 2 {
+				l.t.Errorf("history is %+v", history)
+			}
+		}},
+	{name: "has no history of unknown goods", fn: "get_goods_history", as: asAnonymous,
+		args: []string{"nothere"},
+		check: func(l *testLedger, result []byte) {
+			if string(result) != "[]" {
+				l.t.Errorf("get_goods_history returned %s", result)
+			}
+		}},
+})
+
+func TestListingFunctions(t *testing.T) {
+	runCases(t, listingCases)
+}
+
+func TestGoodsQueryMatches(t *testing.T) {
+	low, high := 10.0, 20.0
+	goods := Goods{GDSID: "g1", Price: 12.5, State: StateListed, Owners: []Owner{{"company2", 8}, {"company1", 2}}}
+	for _, c := range []struct {
+		query GoodsQuery
+		want  bool
+	}{
+		{GoodsQuery{}, true},
+		{GoodsQuery{Owner: "company1", State: StateListed, MinPrice: &low, MaxPrice: &high}, true},
+		{GoodsQuery{Owner: "company3"}, false},
+		{GoodsQuery{State: StateNew}, false},
+		{GoodsQuery{MinPrice: &high}, false},
+		{GoodsQuery{MaxPrice: &low}, false},
+	} {
+		if got := c.query.matches(goods); got != c.want {
+			t.Errorf("%+v matches %v, want %v", c.query, got, c.want)
+		}
+	}
+	if !(GoodsQuery{State: StateNew}).matches(Goods{GDSID: "g2"}) {
+		t.Error("goods without a state do not count as new")
+	}
+}
@@ -0,0 +1,276 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		 http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bien
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// GoodsBackfill holds the values migrate_goods writes into a goods record that lacks them
+type GoodsBackfill struct {
+	Name    string   `json:"name"`
+	Price   *float64 `json:"price"`
+	Postage *float64 `json:"postage"`
+	State   string   `json:"state"`
+}
+
+// MigrationReport is what migrate_goods did
+type MigrationReport struct {
+	Scanned  int      `json:"scanned"`
+	Updated  int      `json:"updated"`
+	Moved    int      `json:"moved"`		//records moved from goods:<id> keys to composite keys
+	Unmapped []string `json:"unmapped"`	//records still missing fields with nothing in the mapping for them
+	UnknownStates map[string]string `json:"unknownStates"`	//legacy states the lifecycle has no match for, by GDSID, the goods were set to new
+}
+
+func init() {
+	registerFunctions(
+		Function{Name: "migrate_goods", Kind: KindInvoke, Description: "Backfills fields goods records were stored without",
+			Args: []ArgSpec{{Name: "mapping", Type: ArgString, Description: "JSON keyed by GDSID or CSV with a header line"}},
+			Returns: MigrationReport{},
+			Roles: []string{RoleAdmin},
+			Handler: (*BienChaincode).migrate_goods},
+	)
+}
+
+// migrate_goods - admin invoke function backfilling the fields goods records were stored without
+func (t *BienChaincode) migrate_goods(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+
+	/*		0
+		json, keyed by GDSID
+		{
+			"COMPANA10000010": {"name": "chair", "price": 12.50, "postage": 3.00, "state": "listed"}
+		}
+		or csv with a header line
+		gdsid,name,price,postage,state
+		COMPANA10000010,chair,12.50,3.00,listed
+	*/
+
+	mapping, err := parseBackfill(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	report := MigrationReport{Unmapped: []string{}, UnknownStates: map[string]string{}}
+
+	// Both ranges are read before anything is written. Records written before
+	// composite keys still live under goods:<id> and are moved while backfilled.
+	legacy, err := scanRaw(stub, goodsPrefix, goodsPrefix+maxUnicodeRune)
+	if err != nil {
+		return nil, err
+	}
+	startKey, endKey, err := compositeKeyRange(goodsObjectType, nil)
+	if err != nil {
+		return nil, err
+	}
+	current, err := scanRaw(stub, startKey, endKey)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, kv := range legacy {
+		err = backfillGoods(stub, kv.value, true, mapping, &report)
+		if err != nil {
+			return nil, err
+		}
+		err = stub.DelState(kv.key)
+		if err != nil {
+			return nil, newError(CodeLedger, "Error removing legacy goods key "+kv.key)
+		}
+		report.Moved++
+	}
+	for _, kv := range current {
+		err = backfillGoods(stub, kv.value, false, mapping, &report)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	fmt.Printf("Migrated goods %+v\n", report)
+	return json.Marshal(&report)
+}
+
+// backfillGoods - fills the missing fields of one stored goods record from the mapping and writes it back
+func backfillGoods(stub shim.ChaincodeStubInterface, value []byte, legacy bool, mapping map[string]GoodsBackfill, report *MigrationReport) error {
+	report.Scanned++
+
+	var goods Goods
+	err := unmarshalRecord(kindGoods, value, &goods)
+	if err != nil {
+		return newError(CodeCorruptRecord, "Error unmarshalling goods record during migration")
+	}
+	// the stored keys tell apart a value that was never stored from a stored zero, a free price stays free
+	var raw map[string]interface{}
+	err = json.Unmarshal(value, &raw)
+	if err != nil {
+		return newError(CodeCorruptRecord, "Error unmarshalling goods "+goods.GDSID+" during migration")
+	}
+	missing := func(field string) bool {
+		v, ok := raw[field]
+		return !ok || v == nil || v == ""
+	}
+
+	backfill, mapped := mapping[goods.GDSID]
+	changed := false
+	stillMissing := false
+	if missing("name") {
+		if mapped && backfill.Name != "" {
+			goods.Name = backfill.Name
+			changed = true
+		} else {
+			stillMissing = true
+		}
+	}
+	if missing("price") {
+		if mapped && backfill.Price != nil {
+			goods.Price = *backfill.Price
+			changed = true
+		} else {
+			stillMissing = true
+		}
+	}
+	if missing("postage") {
+		if mapped && backfill.Postage != nil {
+			goods.Postage = *backfill.Postage
+			changed = true
+		} else {
+			stillMissing = true
+		}
+	}
+	if missing("state") {
+		goods.State = StateNew
+		if mapped && backfill.State != "" {
+			goods.State = normalizeState(backfill.State)
+			if goods.State == "" {
+				return argError("mapping", "Unknown state "+backfill.State+" for goods "+goods.GDSID)
+			}
+		}
+		changed = true
+	}
+	// a free text state nothing matched was set to new by the upgrade, the mapping can give the right one
+	version, err := recordVersion(kindGoods, value)
+	if err != nil {
+		return err
+	}
+	if version < 3 && goods.LegacyState != "" && mapLegacyState(goods.LegacyState) == "" {
+		if mapped && backfill.State != "" {
+			goods.State = normalizeState(backfill.State)
+			if goods.State == "" {
+				return argError("mapping", "Unknown state "+backfill.State+" for goods "+goods.GDSID)
+			}
+			changed = true
+		} else {
+			report.UnknownStates[goods.GDSID] = goods.LegacyState
+		}
+	}
+	if stillMissing {
+		report.Unmapped = append(report.Unmapped, goods.GDSID)
+	}
+
+	if goods.GDSID == "" || goods.Issuer == "" {
+		return newError(CodeCorruptRecord, "Goods record without gdsid or issuer cannot be migrated")
+	}
+	// legacy records are always written, they have to reach their composite key
+	if !changed && !legacy {
+		return nil
+	}
+	if changed {
+		report.Updated++
+	}
+	return putGoods(stub, goods, "migrate")
+}
+
+// parseBackfill - reads the migrate_goods mapping, a JSON object keyed by GDSID or CSV with a header line
+func parseBackfill(arg string) (map[string]GoodsBackfill, error) {
+	mapping := map[string]GoodsBackfill{}
+
+	if strings.HasPrefix(strings.TrimSpace(arg), "{") {
+		err := json.Unmarshal([]byte(arg), &mapping)
+		if err != nil {
+			return nil, argError("mapping", "Invalid goods mapping json")
+		}
+		return mapping, nil
+	}
+
+	records, err := csv.NewReader(strings.NewReader(arg)).ReadAll()
+	if err != nil {
+		return nil, argError("mapping", "Invalid goods mapping csv")
+	}
+	if len(records) == 0 {
+		return mapping, nil
+	}
+	columns := map[string]int{}
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	idCol, ok := columns["gdsid"]
+	if !ok {
+		return nil, argError("mapping", "Goods mapping csv needs a gdsid column")
+	}
+	for line, record := range records[1:] {
+		var backfill GoodsBackfill
+		if i, ok := columns["name"]; ok {
+			backfill.Name = record[i]
+		}
+		if i, ok := columns["state"]; ok {
+			backfill.State = record[i]
+		}
+		if i, ok := columns["price"]; ok && record[i] != "" {
+			price, err := strconv.ParseFloat(record[i], 64)
+			if err != nil {
+				return nil, argError("mapping", "Invalid price on line "+strconv.Itoa(line+2)+" of the goods mapping")
+			}
+			backfill.Price = &price
+		}
+		if i, ok := columns["postage"]; ok && record[i] != "" {
+			postage, err := strconv.ParseFloat(record[i], 64)
+			if err != nil {
+				return nil, argError("mapping", "Invalid postage on line "+strconv.Itoa(line+2)+" of the goods mapping")
+			}
+			backfill.Postage = &postage
+		}
+		mapping[record[idCol]] = backfill
+	}
+	return mapping, nil
+}
+
+type keyValue struct {
+	key   string
+	value []byte
+}
+
+// scanRaw - reads every key and value in the range, in key order, before anything in it is rewritten
+func scanRaw(stub shim.ChaincodeStubInterface, startKey string, endKey string) ([]keyValue, error) {
+	iter, err := stub.RangeQueryState(startKey, endKey)
+	if err != nil {
+		return nil, newError(CodeLedger, "Error scanning state")
+	}
+	defer iter.Close()
+
+	var values []keyValue
+	for iter.HasNext() {
+		key, value, err := iter.Next()
+		if err != nil {
+			return nil, newError(CodeLedger, "Error scanning state")
+		}
+		values = append(values, keyValue{key, value})
+	}
+	return values, nil
+}
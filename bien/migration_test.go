@@ -0,0 +1,148 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		 http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bien
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// goods under goods:OLD2 that were stored without a name or state
+func putOld2(l *testLedger) {
+	l.put(goodsPrefix+"OLD2", `{"goodsId":"OLD2","price":2,"postage":1,`+
+		`"owner":[{"company":"company2","quantity":1}],"issuer":"company2","quantity":1,"schemaVersion":2}`)
+}
+
+// goods of version 2 under goods:OLD4, in a state the lifecycle has no match for
+func putWibble(l *testLedger) {
+	l.put(goodsPrefix+"OLD4", `{"goodsId":"OLD4","name":"cup","price":2,"postage":1,`+
+		`"owner":[{"company":"company2","quantity":1}],"issuer":"company2","state":"wibble","quantity":1,"schemaVersion":2}`)
+}
+
+func migrationReport(l *testLedger, result []byte) MigrationReport {
+	l.t.Helper()
+	var report MigrationReport
+	l.decode(result, &report)
+	return report
+}
+
+var migrationCases = register([]funcCase{
+	{name: "moves goods: records to composite keys", fn: "migrate_goods", as: asAdmin,
+		setup: putOld2,
+		args: []string{`{"OLD2":{"name":"spoon","state":"listed"}}`},
+		check: func(l *testLedger, result []byte) {
+			want := MigrationReport{Scanned: 2, Updated: 1, Moved: 1, Unmapped: []string{}, UnknownStates: map[string]string{}}
+			if report := migrationReport(l, result); !reflect.DeepEqual(report, want) {
+				l.t.Errorf("migrate_goods reported %+v, want %+v", report, want)
+			}
+			if _, ok := l.stub.State[goodsPrefix+"OLD2"]; ok {
+				l.t.Error("OLD2 is still stored under goods:OLD2")
+			}
+			if goods := l.goods("OLD2"); goods.Name != "spoon" || goods.State != StateListed || goods.Price != 2 {
+				l.t.Errorf("migrated %+v", goods)
+			}
+		}},
+	{name: "reports goods the mapping has nothing for", fn: "migrate_goods", as: asAdmin,
+		setup: putOld2,
+		args: []string{"gdsid,name\nOTHER,cup\n"},
+		check: func(l *testLedger, result []byte) {
+			if report := migrationReport(l, result); !reflect.DeepEqual(report.Unmapped, []string{"OLD2"}) {
+				l.t.Errorf("migrate_goods reported %+v", report)
+			}
+		}},
+	{name: "reports states the lifecycle has no match for", fn: "migrate_goods", as: asAdmin,
+		setup: putWibble,
+		args: []string{`{}`},
+		check: func(l *testLedger, result []byte) {
+			if report := migrationReport(l, result); !reflect.DeepEqual(report.UnknownStates, map[string]string{"OLD4": "wibble"}) {
+				l.t.Errorf("migrate_goods reported %+v", report)
+			}
+			checkState(l, "OLD4", StateNew)
+		}},
+	{name: "takes the state of unknown states from the mapping", fn: "migrate_goods", as: asAdmin,
+		setup: putWibble,
+		args: []string{"gdsid,state\nOLD4,ordered\n"},
+		check: func(l *testLedger, result []byte) {
+			if report := migrationReport(l, result); report.Updated != 1 || len(report.UnknownStates) != 0 {
+				l.t.Errorf("migrate_goods reported %+v", report)
+			}
+			checkState(l, "OLD4", StateOrdered)
+		}},
+	{name: "keeps a stored zero price", fn: "migrate_goods", as: asAdmin,
+		setup: func(l *testLedger) {
+			l.put(goodsPrefix+"FREE1", `{"goodsId":"FREE1","name":"leaflet","state":"listed","price":0,"postage":0,`+
+				`"owner":[{"company":"company2","quantity":1}],"issuer":"company2","quantity":1,"schemaVersion":2}`)
+		},
+		args: []string{`{"FREE1":{"price":9}}`},
+		check: func(l *testLedger, result []byte) {
+			if report := migrationReport(l, result); report.Moved != 1 || len(report.Unmapped) != 0 {
+				l.t.Errorf("migrate_goods reported %+v", report)
+			}
+			if goods := l.goods("FREE1"); goods.Price != 0 {
+				l.t.Errorf("the free leaflet costs %v after the migration", goods.Price)
+			}
+		}},
+	{name: "needs known states in the mapping", fn: "migrate_goods", as: asAdmin,
+		setup: putOld2,
+		args: []string{`{"OLD2":{"state":"wobble"}}`},
+		code: CodeInvalidArgument},
+	{name: "needs a mapping it can read", fn: "migrate_goods", as: asAdmin,
+		args: []string{"name,price\nchair,12.5\n"},
+		code: CodeInvalidArgument},
+})
+
+func TestMigrationFunctions(t *testing.T) {
+	runCases(t, migrationCases)
+}
+
+func TestGoodsKeepEveryField(t *testing.T) {
+	goods := Goods{GDSID: "COMPANLG0000015", Name: "chair", Price: 12.5, Postage: 3, Issuer: "company2",
+		State: StateListed, Quantity: 10, Owners: []Owner{{"company2", 10}}}
+	goodsBytes, err := json.Marshal(&goods)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored Goods
+	if err := json.Unmarshal(goodsBytes, &stored); err != nil || !reflect.DeepEqual(stored, goods) {
+		t.Errorf("stored %s, read back %+v", goodsBytes, stored)
+	}
+}
+
+func TestParseBackfill(t *testing.T) {
+	price, postage := 12.5, 3.0
+	want := map[string]GoodsBackfill{
+		"COMPANLG0000015": {Name: "chair", Price: &price, Postage: &postage, State: "listed"},
+		"COMPANLG0000023": {Name: "table"},
+	}
+	for _, arg := range []string{
+		`{"COMPANLG0000015":{"name":"chair","price":12.5,"postage":3,"state":"listed"},"COMPANLG0000023":{"name":"table"}}`,
+		"gdsid,name,price,postage,state\nCOMPANLG0000015,chair,12.50,3.00,listed\nCOMPANLG0000023,table,,,\n",
+		"Name,GDSID,State,Price,Postage\nchair,COMPANLG0000015,listed,12.5,3\ntable,COMPANLG0000023,,,\n",
+	} {
+		mapping, err := parseBackfill(arg)
+		if err != nil || !reflect.DeepEqual(mapping, want) {
+			t.Errorf("parseBackfill(%q) = %+v, %v", arg, mapping, err)
+		}
+	}
+	for _, arg := range []string{
+		`{"COMPANLG0000015":{"price":"cheap"}}`,
+		"name,price\nchair,12.5\n",
+		"gdsid,price\nCOMPANLG0000015,cheap\n",
+	} {
+		if _, err := parseBackfill(arg); err == nil {
+			t.Errorf("parseBackfill(%q) accepted it", arg)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		 http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bien
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Every chaincode function is declared once, next to its handler, with registerFunctions.
+// Invoke and Query look the function up here, check its arguments against the declared
+// ones and the caller's roles against the required ones, and only then call the handler.
+// Whatever fails, the caller gets an *Error.
+const (
+	KindInvoke = "invoke"
+	KindQuery  = "query"
+)
+
+// Argument types
+const (
+	ArgString  = "string"
+	ArgJSON    = "json"
+	ArgNumber  = "number"
+	ArgInteger = "integer"
+)
+
+// ArgSpec declares one positional argument of a function
+type ArgSpec struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Optional    bool   `json:"optional,omitempty"`		//optional arguments come last
+	AllowEmpty  bool   `json:"allowEmpty,omitempty"`		//"" is a valid value, otherwise it counts as missing
+	Description string `json:"description,omitempty"`
+	Model       interface{} `json:"-"`		//value of the Go type a json argument decodes into, for describe
+}
+
+// Handler is the code behind a chaincode function
+type Handler func(t *BienChaincode, stub shim.ChaincodeStubInterface, args []string) ([]byte, error)
+
+// Function is a chaincode function as it is registered with the router
+type Function struct {
+	Name        string
+	Kind        string
+	Roles       []string		//the caller needs one of them, everybody may call the function without any. Overloads share them
+	Description string
+	Args        []ArgSpec
+	Returns     interface{}		//value of the Go type the result encodes, nil when nothing is returned
+	Errors      []string		//codes of the errors the function returns besides routerErrors
+	Handler     Handler
+	Overloads   []Function		//other argument lists the function takes, tried in order when Args does not fit
+}
+
+// functions holds every registered function by name
+var functions = map[string]*Function{}
+
+// registerFunctions - adds functions to the router, called from the init of the file defining them
+func registerFunctions(fns ...Function) {
+	for i := range fns {
+		fn := fns[i]
+		if _, ok := functions[fn.Name]; ok {
+			panic("chaincode function " + fn.Name + " registered twice")
+		}
+		functions[fn.Name] = &fn
+	}
+}
+
+// functionNames - the registered functions of a kind, sorted
+func functionNames(kind string) []string {
+	var names []string
+	for name, fn := range functions {
+		if fn.Kind == kind {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// route - finds the function, validates the arguments and runs the handler
+func (t *BienChaincode) route(stub shim.ChaincodeStubInterface, kind string, function string, args []string) ([]byte, error) {
+	fn, ok := functions[function]
+	if !ok || fn.Kind != kind {
+		fmt.Println(kind + " did not find func: " + function)
+		return nil, &Error{Code: CodeUnknownFunction, Details: functionNames(kind),
+			Message: "Unknown " + kind + " function " + function + ". Available: " + strings.Join(functionNames(kind), ", ")}
+	}
+
+	// an overload is only used when the main argument list does not fit
+	variant := fn
+	if !fitsArity(fn.Args, len(args)) {
+		for i := range fn.Overloads {
+			if fitsArity(fn.Overloads[i].Args, len(args)) {
+				variant = &fn.Overloads[i]
+				break
+			}
+		}
+	}
+	err := validateArgs(fn.Name, variant.Args, args)
+	if err != nil {
+		return nil, err
+	}
+	caller, err := callerIdentity(stub)
+	if err != nil {
+		return nil, asError(err)
+	}
+	err = checkRoles(caller, fn.Name, fn.Roles)
+	if err != nil {
+		return nil, err
+	}
+	result, err := variant.Handler(t, stub, args)
+	if err != nil {
+		return nil, asError(err)
+	}
+	return result, nil
+}
+
+func fitsArity(specs []ArgSpec, n int) bool {
+	required := 0
+	for _, spec := range specs {
+		if !spec.Optional {
+			required++
+		}
+	}
+	return n >= required && n <= len(specs)
+}
+
+// validateArgs - checks the number and the types of the arguments against the declared ones
+func validateArgs(function string, specs []ArgSpec, args []string) error {
+	if !fitsArity(specs, len(args)) {
+		var names []string
+		for _, spec := range specs {
+			if spec.Optional {
+				names = append(names, "["+spec.Name+"]")
+			} else {
+				names = append(names, spec.Name)
+			}
+		}
+		return newError(CodeInvalidArgument, "Incorrect number of arguments for "+function+". Expecting "+
+			strconv.Itoa(len(specs))+": "+strings.Join(names, ", "))
+	}
+
+	for i, arg := range args {
+		spec := specs[i]
+		if arg == "" {
+			if spec.AllowEmpty || spec.Optional {
+				continue
+			}
+			return argError(spec.Name, "Argument "+spec.Name+" of "+function+" must not be empty")
+		}
+		switch spec.Type {
+		case ArgJSON:
+			var v interface{}
+			if json.Unmarshal([]byte(arg), &v) != nil {
+				return argError(spec.Name, "Argument "+spec.Name+" of "+function+" must be JSON")
+			}
+		case ArgNumber:
+			if _, err := strconv.ParseFloat(arg, 64); err != nil {
+				return argError(spec.Name, "Argument "+spec.Name+" of "+function+" must be a number")
+			}
+		case ArgInteger:
+			if _, err := strconv.Atoi(arg); err != nil {
+				return argError(spec.Name, "Argument "+spec.Name+" of "+function+" must be an integer")
+			}
+		}
+	}
+	return nil
+}
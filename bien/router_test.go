@@ -0,0 +1,235 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		 http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bien
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+var describeCases = register([]funcCase{
+	{name: "describes every function", fn: "describe", as: asAnonymous,
+		check: func(l *testLedger, result []byte) {
+			var infos []FunctionInfo
+			l.decode(result, &infos)
+			if len(infos) != len(functions) {
+				l.t.Errorf("describe returned %d functions, %d are registered", len(infos), len(functions))
+			}
+		}},
+	{name: "describes the named function", fn: "describe", as: asAnonymous,
+		args: []string{"add_goods"},
+		check: func(l *testLedger, result []byte) {
+			var infos []FunctionInfo
+			l.decode(result, &infos)
+			if len(infos) != 1 || infos[0].Name != "add_goods" || len(infos[0].Overloads) != 1 ||
+				infos[0].Args[0].Schema == nil || infos[0].Args[0].Schema["title"] != "Goods" {
+				l.t.Errorf("describe add_goods returned %s", result)
+			}
+		}},
+	{name: "describes the result", fn: "describe", as: asAnonymous,
+		args: []string{"get_account"},
+		check: func(l *testLedger, result []byte) {
+			var infos []FunctionInfo
+			l.decode(result, &infos)
+			if len(infos) != 1 || infos[0].Kind != KindQuery || infos[0].Returns["title"] != "Account" {
+				l.t.Errorf("describe get_account returned %s", result)
+			}
+		}},
+	{name: "needs a registered function", fn: "describe", as: asAnonymous,
+		args: []string{"fly"},
+		code: CodeUnknownFunction},
+
+	{name: "describes every event", fn: "describe_events", as: asAnonymous,
+		check: func(l *testLedger, result []byte) {
+			var infos []EventInfo
+			l.decode(result, &infos)
+			if len(infos) != len(eventCatalog) {
+				l.t.Errorf("describe_events returned %d events, %d are declared", len(infos), len(eventCatalog))
+			}
+		}},
+	{name: "describes the named event", fn: "describe_events", as: asAnonymous,
+		args: []string{EventGoodsSold},
+		check: func(l *testLedger, result []byte) {
+			var infos []EventInfo
+			l.decode(result, &infos)
+			if len(infos) != 1 || infos[0].Name != EventGoodsSold || infos[0].Old == nil {
+				l.t.Errorf("describe_events GoodsSold returned %s", result)
+			}
+		}},
+	{name: "needs a declared event", fn: "describe_events", as: asAnonymous,
+		args: []string{"GoodsLost"},
+		code: CodeInvalidArgument},
+})
+
+func TestDescribeFunctions(t *testing.T) {
+	runCases(t, describeCases)
+}
+
+func TestSchemaOf(t *testing.T) {
+	type Inner struct {
+		Count int `json:"count"`
+	}
+	type record struct {
+		Inner
+		Name   string             `json:"name"`
+		Price  *float64           `json:"price,omitempty"`
+		Tags   []string           `json:"tags"`
+		Data   []byte             `json:"data"`
+		Counts map[string]float64 `json:"counts"`
+		Hidden string             `json:"-"`
+		secret string
+	}
+	want := JSONSchema{"type": "object", "title": "record", "properties": JSONSchema{
+		"count":  JSONSchema{"type": "integer"},
+		"name":   JSONSchema{"type": "string"},
+		"price":  JSONSchema{"type": "number"},
+		"tags":   JSONSchema{"type": "array", "items": JSONSchema{"type": "string"}},
+		"data":   JSONSchema{"type": "string", "contentEncoding": "base64"},
+		"counts": JSONSchema{"type": "object", "additionalProperties": JSONSchema{"type": "number"}},
+	}}
+	if got := schemaOf(reflect.TypeOf(record{})); !reflect.DeepEqual(got, want) {
+		t.Errorf("schemaOf(record) = %v, want %v", got, want)
+	}
+
+	type node struct {
+		Children []node `json:"children"`
+	}
+	want = JSONSchema{"type": "object", "title": "node", "properties": JSONSchema{
+		"children": JSONSchema{"type": "array", "items": JSONSchema{"type": "object", "title": "node"}},
+	}}
+	if got := schemaOf(reflect.TypeOf(node{})); !reflect.DeepEqual(got, want) {
+		t.Errorf("schemaOf(node) = %v, want %v", got, want)
+	}
+}
+
+// validArgs - arguments of the declared types for the required arguments of a function
+func validArgs(specs []ArgSpec) []string {
+	var args []string
+	for _, spec := range specs {
+		if spec.Optional {
+			break
+		}
+		switch spec.Type {
+		case ArgJSON:
+			args = append(args, "{}")
+		case ArgNumber, ArgInteger:
+			args = append(args, "1")
+		default:
+			args = append(args, "x")
+		}
+	}
+	return args
+}
+
+// TestRouterChecks sends every function the arguments and callers the router turns away
+// before the handler runs
+func TestRouterChecks(t *testing.T) {
+	l := newFixture(t)
+	var names []string
+	for name := range functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fn := functions[name]
+		t.Run(name, func(t *testing.T) {
+			l.t = t
+
+			tooMany := append(validArgs(fn.Args), "x")
+			for len(tooMany) <= len(fn.Args) {
+				tooMany = append(tooMany, "x")
+			}
+			for _, overload := range fn.Overloads {
+				for len(tooMany) <= len(overload.Args) {
+					tooMany = append(tooMany, "x")
+				}
+			}
+			l.mustFail(CodeInvalidArgument, asAnonymous, name, tooMany...)
+			if required := validArgs(fn.Args); len(required) > 0 {
+				l.mustFail(CodeInvalidArgument, asAnonymous, name, required[:len(required)-1]...)
+			}
+
+			for i, spec := range fn.Args {
+				if spec.Optional || spec.Type == ArgString {
+					continue
+				}
+				args := validArgs(fn.Args)
+				args[i] = "not a " + spec.Type
+				_, err := l.call(asAnonymous, name, args...)
+				if e := ParseError(err); e.Code != CodeInvalidArgument || e.Arg != spec.Name {
+					t.Errorf("%s with an invalid %s returned %v", name, spec.Name, err)
+				}
+			}
+
+			if len(fn.Roles) == 0 {
+				return
+			}
+			l.mustFail(CodeUnauthenticated, asAnonymous, name, validArgs(fn.Args)...)
+			for _, role := range knownRoles {
+				if containsString(fn.Roles, role) || role == RoleAdmin {
+					continue
+				}
+				caller := testCaller{"mallory", "company1", role}
+				if role == RoleAuditor {
+					caller = asAudrey
+				}
+				l.mustFail(CodeForbidden, caller, name, validArgs(fn.Args)...)
+			}
+		})
+	}
+}
+
+func TestUnknownFunctions(t *testing.T) {
+	l := newFixture(t)
+	if _, err := l.stub.MockInvoke("fly", nil); errorCode(err) != CodeUnknownFunction {
+		t.Errorf("invoking fly returned %v", err)
+	}
+	// queries are not invoked, nor invokes queried
+	if _, err := l.stub.MockInvoke("GetGD", []string{"x"}); errorCode(err) != CodeUnknownFunction {
+		t.Errorf("invoking GetGD returned %v", err)
+	}
+	if _, err := l.stub.MockQuery("add_goods", []string{"{}"}); errorCode(err) != CodeUnknownFunction {
+		t.Errorf("querying add_goods returned %v", err)
+	}
+}
+
+// TestEveryFunctionIsCovered checks that the case tables hold a successful call of every
+// registered function and a failing one for every error code it declares
+func TestEveryFunctionIsCovered(t *testing.T) {
+	covered := map[string]map[string]bool{}
+	for _, cases := range caseTables {
+		for _, c := range cases {
+			if covered[c.fn] == nil {
+				covered[c.fn] = map[string]bool{}
+			}
+			covered[c.fn][c.code] = true
+		}
+	}
+	for name, fn := range functions {
+		if !covered[name][""] {
+			t.Errorf("no case calls %s successfully", name)
+		}
+		codes := append([]string{}, fn.Errors...)
+		for _, overload := range fn.Overloads {
+			codes = append(codes, overload.Errors...)
+		}
+		for _, code := range codes {
+			if !covered[name][code] {
+				t.Errorf("no case makes %s fail with %s", name, code)
+			}
+		}
+	}
+}
@@ -0,0 +1,419 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		 http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bien
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Every stored record carries the schemaVersion of its kind. Records are upgraded
+// one version at a time by the functions in schemaUpgrades when they are read, and
+// upgrade_state rewrites all outdated records in bulk. Changing a record's shape
+// means bumping its version here and registering the upgrade from the old one.
+const (
+	kindGoods       = "goods"
+	kindAccount     = "account"
+	kindTrades      = "trades"
+	kindTransaction = "transaction"
+	kindHistory     = "history"
+	kindIDScheme    = "idscheme"
+	kindIdentity    = "identity"
+	kindCompany     = "company"
+	kindKV          = "kv"
+)
+
+// schemaVersions are the versions records are written with
+var schemaVersions = map[string]int{
+	kindGoods:       3,
+	kindAccount:     1,
+	kindTrades:      1,
+	kindTransaction: 1,
+	kindHistory:     1,
+	kindIDScheme:    1,
+	kindIdentity:    1,
+	kindCompany:     1,
+	kindKV:          1,
+}
+
+// upgradeFunc rewrites a decoded record of one version into the shape of the next
+type upgradeFunc func(record map[string]interface{}) error
+
+// schemaUpgrades holds, per kind, the upgrade out of every old version
+var schemaUpgrades = map[string]map[int]upgradeFunc{
+	kindGoods: {
+		0: upgradeBienToGoods,
+		1: upgradeGoodsQuantities,
+		2: upgradeGoodsStates,
+	},
+	// records written before versioning already have the version 1 shape
+	kindAccount:     {0: noUpgrade},
+	kindTrades:      {0: noUpgrade},
+	kindTransaction: {0: noUpgrade},
+	kindHistory:     {0: noUpgrade},
+	kindIDScheme:    {0: noUpgrade},
+	kindIdentity:    {},
+	kindCompany:     {},
+	kindKV:          {},
+}
+
+// UpgradeRequest limits what upgrade_state rewrites
+type UpgradeRequest struct {
+	Kinds      []string `json:"kinds"`		//all kinds when empty
+	LegacyKeys []string `json:"legacyKeys"`	//keys of Bien records to import as goods
+}
+
+// UpgradeReport is what upgrade_state rewrote, by kind
+type UpgradeReport struct {
+	Upgraded map[string]int `json:"upgraded"`
+}
+
+func init() {
+	registerFunctions(
+		Function{Name: "upgrade_state", Kind: KindInvoke, Description: "Rewrites every outdated record in the current schema",
+			Args: []ArgSpec{{Name: "request", Type: ArgJSON, Optional: true, Description: "kinds and legacyKeys", Model: UpgradeRequest{}}},
+			Returns: UpgradeReport{},
+			Errors: []string{CodeGoodsNotFound},
+			Roles: []string{RoleAdmin},
+			Handler: (*BienChaincode).upgrade_state},
+	)
+}
+
+// upgrade_state - invoke function rewriting every outdated record in the current schema
+func (t *BienChaincode) upgrade_state(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+
+	/*		0 (optional)
+		json
+		{
+			"kinds": ["goods", "account"],		// all kinds without it
+			"legacyKeys": ["1479891234"]			// Bien records stored under their own id, imported as goods
+		}
+	*/
+	var request UpgradeRequest
+	if len(args) == 1 && args[0] != "" {
+		err := json.Unmarshal([]byte(args[0]), &request)
+		if err != nil {
+			return nil, argError("request", "Invalid upgrade request")
+		}
+	}
+	if len(request.Kinds) == 0 {
+		request.Kinds = []string{kindGoods, kindAccount, kindTrades, kindTransaction, kindHistory, kindIDScheme, kindIdentity, kindCompany, kindKV}
+	}
+
+	report := UpgradeReport{Upgraded: map[string]int{}}
+	for _, kind := range request.Kinds {
+		count, err := upgradeKind(stub, kind)
+		if err != nil {
+			return nil, err
+		}
+		report.Upgraded[kind] = count
+	}
+
+	for _, key := range request.LegacyKeys {
+		err := importLegacyBien(stub, key)
+		if err != nil {
+			return nil, err
+		}
+		report.Upgraded[kindGoods]++
+	}
+
+	fmt.Printf("Upgraded state %+v\n", report)
+	return json.Marshal(&report)
+}
+
+// upgradeKind - rewrites the outdated records of one kind, returns how many there were
+func upgradeKind(stub shim.ChaincodeStubInterface, kind string) (int, error) {
+	var records []keyValue
+	var err error
+	switch kind {
+	case kindGoods:
+		records, err = scanComposite(stub, goodsObjectType)
+	case kindHistory:
+		records, err = scanComposite(stub, historyObjectType)
+	case kindIDScheme:
+		records, err = scanComposite(stub, idSchemeObjectType)
+	case kindIdentity:
+		records, err = scanComposite(stub, identityObjectType)
+	case kindCompany:
+		records, err = scanComposite(stub, companyObjectType)
+	case kindKV:
+		records, err = scanComposite(stub, kvObjectType)
+	case kindAccount:
+		records, err = scanRaw(stub, accountPrefix, accountPrefix+maxUnicodeRune)
+	case kindTransaction:
+		records, err = scanRaw(stub, transferPrefix, transferPrefix+maxUnicodeRune)
+	case kindTrades:
+		var tradesBytes []byte
+		tradesBytes, err = stub.GetState(openTradesStr)
+		if err != nil {
+			return 0, newError(CodeLedger, "Failed to get open trades")
+		}
+		if tradesBytes != nil {
+			records = []keyValue{{openTradesStr, tradesBytes}}
+		}
+	default:
+		return 0, argError("request", "Unknown record kind "+kind)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, kv := range records {
+		if kind == kindTransaction && !isJSONObject(kv.value) {
+			// transfer:<GDSID> keys hold the transfer sequence, not a record
+			continue
+		}
+		version, err := recordVersion(kind, kv.value)
+		if err != nil {
+			return 0, err
+		}
+		if version == schemaVersions[kind] {
+			continue
+		}
+
+		if kind == kindGoods {
+			// goods go through putGoods so the upgrade shows up in their history
+			var goods Goods
+			err = unmarshalRecord(kind, kv.value, &goods)
+			if err != nil {
+				return 0, err
+			}
+			err = putGoods(stub, goods, "upgrade")
+		} else {
+			var upgraded []byte
+			upgraded, err = upgradeRecord(kind, kv.value)
+			if err != nil {
+				return 0, err
+			}
+			err = stub.PutState(kv.key, upgraded)
+			if err != nil {
+				err = newError(CodeLedger, "Error writing "+kind+" record "+kv.key)
+			}
+		}
+		if err != nil {
+			return 0, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// importLegacyBien - moves a Bien record stored under its own id into the goods composite keys
+func importLegacyBien(stub shim.ChaincodeStubInterface, key string) error {
+	bienBytes, err := stub.GetState(key)
+	if err != nil {
+		return newError(CodeLedger, "Error retrieving legacy record "+key)
+	}
+	if bienBytes == nil {
+		return newError(CodeGoodsNotFound, "No legacy record under "+key)
+	}
+	version, err := recordVersion(kindGoods, bienBytes)
+	if err != nil {
+		return err
+	}
+	if version != 0 {
+		return argError("request", "Record under "+key+" is not a legacy bien record")
+	}
+
+	var goods Goods
+	err = unmarshalRecord(kindGoods, bienBytes, &goods)
+	if err != nil {
+		return err
+	}
+	if goods.GDSID == "" {
+		goods.GDSID = key
+	}
+	err = putGoods(stub, goods, "upgrade")
+	if err != nil {
+		return err
+	}
+	err = stub.DelState(key)
+	if err != nil {
+		return newError(CodeLedger, "Error removing legacy record "+key)
+	}
+	return nil
+}
+
+// unmarshalRecord - decodes a stored record into v after upgrading it to the current schema
+func unmarshalRecord(kind string, data []byte, v interface{}) error {
+	upgraded, err := upgradeRecord(kind, data)
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(upgraded, v)
+	if err != nil {
+		return newError(CodeCorruptRecord, "Error unmarshalling "+kind+" record")
+	}
+	return nil
+}
+
+// upgradeRecord - runs the upgrades a stored record needs and returns it in the current schema
+func upgradeRecord(kind string, data []byte) ([]byte, error) {
+	version, err := recordVersion(kind, data)
+	if err != nil {
+		return nil, err
+	}
+	current := schemaVersions[kind]
+	if version == current {
+		return data, nil
+	}
+	if version > current {
+		return nil, newError(CodeUnsupportedSchema, "Stored "+kind+" record has schema version "+strconv.Itoa(version)+
+			", newer than this chaincode knows")
+	}
+
+	var record map[string]interface{}
+	err = json.Unmarshal(data, &record)
+	if err != nil {
+		return nil, newError(CodeCorruptRecord, "Error unmarshalling "+kind+" record for upgrade")
+	}
+	for ; version < current; version++ {
+		upgrade, ok := schemaUpgrades[kind][version]
+		if !ok {
+			return nil, newError(CodeUnsupportedSchema, "No upgrade for "+kind+" records from schema version "+strconv.Itoa(version))
+		}
+		err = upgrade(record)
+		if err != nil {
+			return nil, err
+		}
+	}
+	record["schemaVersion"] = current
+	return json.Marshal(record)
+}
+
+// recordVersion - the schema version of a stored record, working out the version of records written before versioning
+func recordVersion(kind string, data []byte) (int, error) {
+	var header map[string]json.RawMessage
+	err := json.Unmarshal(data, &header)
+	if err != nil {
+		return 0, newError(CodeCorruptRecord, "Error unmarshalling "+kind+" record")
+	}
+	if raw, ok := header["schemaVersion"]; ok {
+		var version int
+		err = json.Unmarshal(raw, &version)
+		if err != nil {
+			return 0, newError(CodeCorruptRecord, "Invalid schema version in "+kind+" record")
+		}
+		return version, nil
+	}
+	if kind != kindGoods {
+		return 0, nil
+	}
+	// Bien records keep the owner as a plain string, Goods as a list of owners
+	if raw, ok := header["owner"]; ok && len(raw) > 0 && raw[0] == '"' {
+		return 0, nil
+	}
+	if _, ok := header["orderId"]; ok {
+		return 0, nil
+	}
+	return 1, nil
+}
+
+func noUpgrade(record map[string]interface{}) error {
+	return nil
+}
+
+// upgradeBienToGoods - version 0 is the Bien of chaincode_new and chaincode-back:
+// {orderId, name, state, price int, postage int, owner string}
+func upgradeBienToGoods(record map[string]interface{}) error {
+	id := ""
+	for _, field := range []string{"orderId", "id"} {
+		switch v := record[field].(type) {
+		case string:
+			id = v
+		case float64:
+			id = strconv.FormatInt(int64(v), 10)
+		}
+		delete(record, field)
+		if id != "" {
+			break
+		}
+	}
+	record["goodsId"] = id
+
+	owner, _ := record["owner"].(string)
+	if owner != "" {
+		record["owner"] = []interface{}{map[string]interface{}{"company": owner, "quantity": 1}}
+		record["issuer"] = owner
+	} else {
+		record["owner"] = []interface{}{}
+	}
+	record["quantity"] = 1
+	return nil
+}
+
+// upgradeGoodsQuantities - version 1 goods predate quantities: every owner held a single unit
+func upgradeGoodsQuantities(record map[string]interface{}) error {
+	owners, _ := record["owner"].([]interface{})
+	total := 0
+	for _, o := range owners {
+		owner, ok := o.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		quantity, _ := owner["quantity"].(float64)
+		if quantity <= 0 {
+			quantity = 1
+			owner["quantity"] = quantity
+		}
+		total += int(quantity)
+	}
+	if issued, _ := record["quantity"].(float64); int(issued) < total || issued <= 0 {
+		if total == 0 {
+			total = 1
+		}
+		record["quantity"] = total
+	}
+	if state, _ := record["state"].(string); state == "" {
+		record["state"] = StateNew
+	}
+	return nil
+}
+
+// upgradeGoodsStates - goods written before the lifecycle may hold any free text state: it is
+// mapped onto the lifecycle, unknown states start over as new, the original stays in legacyState
+func upgradeGoodsStates(record map[string]interface{}) error {
+	state, _ := record["state"].(string)
+	if state == "" {
+		record["state"] = StateNew
+		return nil
+	}
+	if normalizeState(state) == state {
+		return nil
+	}
+	mapped := mapLegacyState(state)
+	if mapped == "" {
+		mapped = StateNew
+	}
+	record["state"] = mapped
+	record["legacyState"] = state
+	return nil
+}
+
+func scanComposite(stub shim.ChaincodeStubInterface, objectType string) ([]keyValue, error) {
+	startKey, endKey, err := compositeKeyRange(objectType, nil)
+	if err != nil {
+		return nil, err
+	}
+	return scanRaw(stub, startKey, endKey)
+}
+
+func isJSONObject(data []byte) bool {
+	var object map[string]json.RawMessage
+	return json.Unmarshal(data, &object) == nil
+}
@@ -0,0 +1,130 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		 http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bien
+
+import (
+	"reflect"
+	"testing"
+)
+
+// putComposite - stores a goods record of company2 under its composite key, with its locator
+func putComposite(id string, record string) func(l *testLedger) {
+	return func(l *testLedger) {
+		l.put(compositeKey(goodsObjectType, "company2", id), record)
+		l.put(compositeKey(gdsidObjectType, id), "company2")
+	}
+}
+
+// goods of version 1, before quantities, with a free text state
+var putOld1 = putComposite("OLD1", `{"goodsId":"OLD1","name":"bowl","price":3,"postage":1,`+
+	`"owner":[{"company":"company2"}],"issuer":"company2","state":"shipped2"}`)
+
+func upgradeReport(l *testLedger, result []byte) UpgradeReport {
+	l.t.Helper()
+	var report UpgradeReport
+	l.decode(result, &report)
+	return report
+}
+
+var schemaCases = register([]funcCase{
+	{name: "rewrites outdated goods", fn: "upgrade_state", as: asAdmin,
+		setup: putOld1,
+		check: func(l *testLedger, result []byte) {
+			if report := upgradeReport(l, result); report.Upgraded[kindGoods] != 1 {
+				l.t.Errorf("upgrade_state reported %+v", report)
+			}
+			var stored map[string]interface{}
+			l.decode(l.stub.State[compositeKey(goodsObjectType, "company2", "OLD1")], &stored)
+			if stored["schemaVersion"] != float64(3) || stored["quantity"] != float64(1) {
+				l.t.Errorf("OLD1 is stored as %v", stored)
+			}
+			if goods := l.goods("OLD1"); goods.State != StateShipped || goods.LegacyState != "shipped2" {
+				l.t.Errorf("upgraded %+v", goods)
+			}
+		}},
+	{name: "imports the legacy keys", fn: "upgrade_state", as: asAdmin,
+		setup: putBien,
+		args: []string{`{"kinds":["goods"],"legacyKeys":["1479891234"]}`},
+		check: func(l *testLedger, result []byte) {
+			if report := upgradeReport(l, result); !reflect.DeepEqual(report.Upgraded, map[string]int{kindGoods: 1}) {
+				l.t.Errorf("upgrade_state reported %+v", report)
+			}
+			importedGoods(l)
+		}},
+	{name: "needs a record under every legacy key", fn: "upgrade_state", as: asAdmin,
+		setup: putOld1,
+		args: []string{`{"legacyKeys":["nothere"]}`},
+		code: CodeGoodsNotFound},
+	{name: "does not downgrade records of a newer chaincode", fn: "upgrade_state", as: asAdmin,
+		setup: func(l *testLedger) {
+			putOld1(l)
+			putComposite("NEW1", `{"goodsId":"NEW1","name":"pot","issuer":"company2","schemaVersion":99}`)(l)
+		},
+		code: CodeUnsupportedSchema},
+	{name: "needs known kinds", fn: "upgrade_state", as: asAdmin,
+		args: []string{`{"kinds":["widgets"]}`},
+		code: CodeInvalidArgument},
+})
+
+func TestSchemaFunctions(t *testing.T) {
+	runCases(t, schemaCases)
+}
+
+func TestUpgradeGoodsRecords(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		stored string
+		want   Goods
+	}{
+		{name: "a Bien of chaincode_new",
+			stored: `{"orderId":1479891234,"name":"chair","state":"listed","price":12,"postage":3,"owner":"company2"}`,
+			want: Goods{GDSID: "1479891234", Name: "chair", Price: 12, Postage: 3, Issuer: "company2", State: StateListed,
+				Quantity: 1, Owners: []Owner{{"company2", 1}}}},
+		{name: "goods without quantities",
+			stored: `{"goodsId":"g1","name":"chair","issuer":"company2","owner":[{"company":"company2"},{"company":"company1"}]}`,
+			want: Goods{GDSID: "g1", Name: "chair", Issuer: "company2", State: StateNew, Quantity: 2,
+				Owners: []Owner{{"company2", 1}, {"company1", 1}}}},
+		{name: "goods with a free text state",
+			stored: `{"goodsId":"g1","issuer":"company2","state":"In Transit","quantity":1,"owner":[{"company":"company2","quantity":1}],"schemaVersion":2}`,
+			want: Goods{GDSID: "g1", Issuer: "company2", State: StateShipped, LegacyState: "In Transit", Quantity: 1,
+				Owners: []Owner{{"company2", 1}}}},
+		{name: "goods in a state nothing matches",
+			stored: `{"goodsId":"g1","issuer":"company2","state":"wibble","quantity":1,"owner":[{"company":"company2","quantity":1}],"schemaVersion":2}`,
+			want: Goods{GDSID: "g1", Issuer: "company2", State: StateNew, LegacyState: "wibble", Quantity: 1,
+				Owners: []Owner{{"company2", 1}}}},
+		{name: "current goods",
+			stored: `{"goodsId":"g1","issuer":"company2","state":"paid","quantity":10,"owner":[{"company":"company2","quantity":10}],"schemaVersion":3}`,
+			want: Goods{GDSID: "g1", Issuer: "company2", State: StatePaid, Quantity: 10, Owners: []Owner{{"company2", 10}}}},
+	} {
+		var goods Goods
+		err := unmarshalRecord(kindGoods, []byte(c.stored), &goods)
+		goods.SchemaVersion = 0
+		if err != nil || !reflect.DeepEqual(goods, c.want) {
+			t.Errorf("%s: read %+v, %v, want %+v", c.name, goods, err, c.want)
+		}
+	}
+}
+
+func TestUpgradeRecordVersions(t *testing.T) {
+	upgraded, err := upgradeRecord(kindAccount, []byte(`{"id":"acct:company1","cashBalance":100}`))
+	if version, _ := recordVersion(kindAccount, upgraded); err != nil || version != schemaVersions[kindAccount] {
+		t.Errorf("upgraded the account to %s, %v", upgraded, err)
+	}
+	if _, err := upgradeRecord(kindGoods, []byte(`{"goodsId":"g1","schemaVersion":4}`)); err == nil {
+		t.Error("upgraded goods of a schema version newer than the chaincode")
+	}
+	if _, err := upgradeRecord(kindGoods, []byte(`[1,2]`)); err == nil {
+		t.Error("upgraded a record that is not an object")
+	}
+}
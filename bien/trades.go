@@ -0,0 +1,346 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		 http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bien
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Sides of an open trade
+const (
+	TradeSell = "sell"
+	TradeBuy  = "buy"
+)
+
+// Trade is an offer to sell goods the company owns, or to buy goods from their owners
+type Trade struct {
+	ID        string  `json:"id"`
+	Side      string  `json:"side"`
+	Company   string  `json:"company"`
+	GDSID     string  `json:"gdsid"`
+	Price     float64 `json:"price"`		//price of a single unit
+	Quantity  int     `json:"quantity"`		//units still open
+	Expiry    int64   `json:"expiry"`		//ms since epoch, 0 never expires
+	Timestamp int64   `json:"timestamp"`
+}
+
+// AllTrades is the order book stored under openTradesStr
+type AllTrades struct {
+	OpenTrades    []Trade `json:"open_trades"`
+	SchemaVersion int     `json:"schemaVersion"`
+}
+
+func init() {
+	registerFunctions(
+		Function{Name: "open_trade", Kind: KindInvoke, Description: "Posts a sell or buy offer, returns its id",
+			Args: []ArgSpec{{Name: "trade", Type: ArgJSON, Description: "side, company, gdsid, price, quantity and expiry", Model: Trade{}}},
+			Returns: "",
+			Errors: []string{CodeGoodsNotFound, CodeGoodsInactive, CodeInsufficientQuantity, CodeAccountNotFound, CodeInsufficientFunds,
+				CodeCompanyNotFound, CodeCompanySuspended},
+			Roles: []string{RoleIssuer, RoleBuyer},
+			Handler: (*BienChaincode).open_trade},
+		Function{Name: "cancel_trade", Kind: KindInvoke, Description: "Withdraws an open offer",
+			Args: []ArgSpec{{Name: "id", Type: ArgString}, {Name: "company", Type: ArgString}},
+			Errors: []string{CodeTradeNotFound, CodeNotOwner},
+			Roles: []string{RoleIssuer, RoleBuyer},
+			Handler: (*BienChaincode).cancel_trade},
+		Function{Name: "accept_trade", Kind: KindInvoke, Description: "Takes up an open offer",
+			Args: []ArgSpec{{Name: "id", Type: ArgString}, {Name: "company", Type: ArgString},
+				{Name: "quantity", Type: ArgInteger, Optional: true}},
+			Errors: []string{CodeTradeNotFound, CodeGoodsNotFound, CodeGoodsInactive, CodeNotOwner, CodeInsufficientQuantity,
+				CodeAccountNotFound, CodeInsufficientFunds, CodeCompanyNotFound, CodeCompanySuspended},
+			Roles: []string{RoleIssuer, RoleBuyer},
+			Handler: (*BienChaincode).accept_trade},
+		Function{Name: "list_open_trades", Kind: KindQuery, Description: "The open offers, of one goods when given",
+			Args: []ArgSpec{{Name: "gdsid", Type: ArgString, Optional: true}},
+			Returns: AllTrades{},
+			Handler: (*BienChaincode).list_open_trades},
+	)
+}
+
+// open_trade - invoke function to post a sell or buy offer on the order book
+func (t *BienChaincode) open_trade(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+
+	/*		0
+		json
+		{
+			"side": "sell",
+			"company": "company2",
+			"gdsid": "COMPANA10000010",
+			"price": 12.50,
+			"quantity": 3,
+			"expiry": 1480000000000		// optional
+		}
+	*/
+
+	var trade Trade
+	err := json.Unmarshal([]byte(args[0]), &trade)
+	if err != nil {
+		fmt.Println(err)
+		return nil, argError("trade", "Invalid trade")
+	}
+	if trade.Side != TradeSell && trade.Side != TradeBuy {
+		return nil, argError("trade", "Trade side must be sell or buy")
+	}
+	if trade.Company == "" || trade.GDSID == "" {
+		return nil, argError("trade", "Trade needs a company and gdsid")
+	}
+	caller, err := callerIdentity(stub)
+	if err != nil {
+		return nil, err
+	}
+	err = requireCompany(caller, trade.Company)
+	if err != nil {
+		return nil, err
+	}
+	err = checkCompanies(stub, trade.Company)
+	if err != nil {
+		return nil, err
+	}
+	if trade.Price <= 0 || trade.Quantity <= 0 {
+		return nil, argError("trade", "Trade needs a positive price and quantity")
+	}
+
+	now, err := txTimestamp(stub)
+	if err != nil {
+		return nil, err
+	}
+	if trade.Expiry != 0 && trade.Expiry <= now {
+		return nil, argError("trade", "Trade expiry is in the past")
+	}
+	trade.ID = stub.GetTxID()
+	trade.Timestamp = now
+
+	goods, err := getGoods(stub, trade.GDSID)
+	if err != nil {
+		return nil, err
+	}
+	if isTerminalState(currentState(goods)) {
+		return nil, newError(CodeGoodsInactive, "Goods "+goods.GDSID+" is "+currentState(goods)+" and cannot be traded")
+	}
+
+	trades, err := getOpenTrades(stub, now)
+	if err != nil {
+		return nil, err
+	}
+	if trade.Side == TradeSell {
+		// A company cannot offer more than it holds across all of its sell offers
+		offered := trade.Quantity
+		for _, open := range trades.OpenTrades {
+			if open.Side == TradeSell && open.Company == trade.Company && open.GDSID == trade.GDSID {
+				offered += open.Quantity
+			}
+		}
+		if offered > ownedBy(goods, trade.Company) {
+			return nil, newError(CodeInsufficientQuantity, trade.Company+" does not own enough of goods "+trade.GDSID)
+		}
+	} else {
+		account, err := getAccount(stub, trade.Company)
+		if err != nil {
+			return nil, err
+		}
+		// A company cannot bid more than its balance across all of its buy offers
+		bid := trade.Price * float64(trade.Quantity)
+		for _, open := range trades.OpenTrades {
+			if open.Side == TradeBuy && open.Company == trade.Company {
+				bid += open.Price * float64(open.Quantity)
+			}
+		}
+		if account.CashBalance < bid {
+			return nil, newError(CodeInsufficientFunds, "Insufficient funds in account of "+trade.Company)
+		}
+	}
+
+	trades.OpenTrades = append(trades.OpenTrades, trade)
+	err = putOpenTrades(stub, trades)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Println("Opened trade " + trade.ID)
+	return []byte(trade.ID), nil
+}
+
+// cancel_trade - invoke function to withdraw an open trade
+func (t *BienChaincode) cancel_trade(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	//   0        1
+	// trade   company
+	caller, err := callerIdentity(stub)
+	if err != nil {
+		return nil, err
+	}
+	err = requireCompany(caller, args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	now, err := txTimestamp(stub)
+	if err != nil {
+		return nil, err
+	}
+	trades, err := getOpenTrades(stub, now)
+	if err != nil {
+		return nil, err
+	}
+	idx := findTrade(trades, args[0])
+	if idx < 0 {
+		return nil, newError(CodeTradeNotFound, "No open trade "+args[0])
+	}
+	if trades.OpenTrades[idx].Company != args[1] {
+		return nil, newError(CodeNotOwner, "Trade "+args[0]+" was not opened by "+args[1])
+	}
+
+	trades.OpenTrades = append(trades.OpenTrades[:idx], trades.OpenTrades[idx+1:]...)
+	return nil, putOpenTrades(stub, trades)
+}
+
+// list_open_trades - query function returning the open trades that have not expired, optionally for one GDSID
+func (t *BienChaincode) list_open_trades(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	now, err := txTimestamp(stub)
+	if err != nil {
+		return nil, err
+	}
+	trades, err := getOpenTrades(stub, now)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 1 {
+		filtered := []Trade{}
+		for _, trade := range trades.OpenTrades {
+			if trade.GDSID == args[0] {
+				filtered = append(filtered, trade)
+			}
+		}
+		trades.OpenTrades = filtered
+	}
+	return json.Marshal(&trades)
+}
+
+// accept_trade - invoke function to take an open trade, the goods move and are paid for in the same transaction
+func (t *BienChaincode) accept_trade(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	//   0        1          2
+	// trade   company   quantity (optional, the whole open quantity without it)
+	caller, err := callerIdentity(stub)
+	if err != nil {
+		return nil, err
+	}
+	err = requireCompany(caller, args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	now, err := txTimestamp(stub)
+	if err != nil {
+		return nil, err
+	}
+	trades, err := getOpenTrades(stub, now)
+	if err != nil {
+		return nil, err
+	}
+	idx := findTrade(trades, args[0])
+	if idx < 0 {
+		return nil, newError(CodeTradeNotFound, "No open trade "+args[0])
+	}
+	trade := trades.OpenTrades[idx]
+	if trade.Company == args[1] {
+		return nil, argError("company", "A company cannot accept its own trade")
+	}
+
+	quantity := trade.Quantity
+	if len(args) == 3 {
+		quantity, err = strconv.Atoi(args[2])
+		if err != nil || quantity <= 0 {
+			return nil, argError("quantity", "Quantity must be a positive integer")
+		}
+		if quantity > trade.Quantity {
+			return nil, newError(CodeInsufficientQuantity, "Trade "+trade.ID+" only has "+strconv.Itoa(trade.Quantity)+" open")
+		}
+	}
+
+	tr := Transaction{GDSID: trade.GDSID, Quantity: quantity, Price: trade.Price}
+	if trade.Side == TradeSell {
+		tr.FromCompany = trade.Company
+		tr.ToCompany = args[1]
+	} else {
+		tr.FromCompany = args[1]
+		tr.ToCompany = trade.Company
+	}
+	err = purchase(stub, &tr)
+	if err != nil {
+		return nil, err
+	}
+
+	trades.OpenTrades[idx].Quantity -= quantity
+	if trades.OpenTrades[idx].Quantity == 0 {
+		trades.OpenTrades = append(trades.OpenTrades[:idx], trades.OpenTrades[idx+1:]...)
+	}
+	err = putOpenTrades(stub, trades)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("Trade %s accepted by %s for %d\n", trade.ID, args[1], quantity)
+	return nil, nil
+}
+
+// getOpenTrades - reads the order book, trades that expired before now are left out
+func getOpenTrades(stub shim.ChaincodeStubInterface, now int64) (AllTrades, error) {
+	var trades AllTrades
+
+	tradesBytes, err := stub.GetState(openTradesStr)
+	if err != nil {
+		return trades, newError(CodeLedger, "Failed to get open trades")
+	}
+	if tradesBytes != nil {
+		err = unmarshalRecord(kindTrades, tradesBytes, &trades)
+		if err != nil {
+			return trades, newError(CodeCorruptRecord, "Error unmarshalling open trades")
+		}
+	}
+
+	open := []Trade{}
+	for _, trade := range trades.OpenTrades {
+		if trade.Expiry == 0 || trade.Expiry > now {
+			open = append(open, trade)
+		}
+	}
+	trades.OpenTrades = open
+	return trades, nil
+}
+
+// putOpenTrades - writes the order book back
+func putOpenTrades(stub shim.ChaincodeStubInterface, trades AllTrades) error {
+	trades.SchemaVersion = schemaVersions[kindTrades]
+	tradesBytes, err := json.Marshal(&trades)
+	if err != nil {
+		return newError(CodeInternal, "Error marshalling open trades")
+	}
+	err = stub.PutState(openTradesStr, tradesBytes)
+	if err != nil {
+		return newError(CodeLedger, "Error writing open trades")
+	}
+	return nil
+}
+
+func findTrade(trades AllTrades, id string) int {
+	for i, trade := range trades.OpenTrades {
+		if trade.ID == id {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,231 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		 http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bien
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// openTrade - opens a trade as the caller, its id becomes {trade}
+func openTrade(c testCaller, trade string) func(l *testLedger) {
+	return func(l *testLedger) {
+		l.vars["trade"] = string(l.must(c, "open_trade", trade))
+	}
+}
+
+// company2 offers 3 chairs at 10
+var openSell = openTrade(asCarol, `{"side":"sell","company":"company2","gdsid":"{gdsid}","price":10,"quantity":3}`)
+
+// company1 bids for 2 chairs at 10
+var openBuy = openTrade(asAlice, `{"side":"buy","company":"company1","gdsid":"{gdsid}","price":10,"quantity":2}`)
+
+func openTrades(l *testLedger, args ...string) []Trade {
+	l.t.Helper()
+	var trades AllTrades
+	l.decode(l.must(asAnonymous, "list_open_trades", args...), &trades)
+	return trades.OpenTrades
+}
+
+var tradeCases = register([]funcCase{
+	{name: "offers goods the company owns", fn: "open_trade", as: asCarol,
+		args: []string{`{"side":"sell","company":"company2","gdsid":"{gdsid}","price":10,"quantity":3}`},
+		check: func(l *testLedger, result []byte) {
+			trades := openTrades(l)
+			if len(trades) != 1 || trades[0].ID != string(result) || trades[0].Timestamp != testTime.UnixNano()/1e6 {
+				l.t.Errorf("open trades are %+v, want trade %s", trades, result)
+			}
+		}},
+	{name: "bids for goods the company can pay", fn: "open_trade", as: asAlice,
+		args: []string{`{"side":"buy","company":"company1","gdsid":"{gdsid}","price":10,"quantity":10}`}},
+	{name: "needs a side", fn: "open_trade", as: asCarol,
+		args: []string{`{"side":"swap","company":"company2","gdsid":"{gdsid}","price":10,"quantity":3}`},
+		code: CodeInvalidArgument},
+	{name: "needs a price and a quantity", fn: "open_trade", as: asCarol,
+		args: []string{`{"side":"sell","company":"company2","gdsid":"{gdsid}","quantity":3}`},
+		code: CodeInvalidArgument},
+	{name: "needs existing goods", fn: "open_trade", as: asCarol,
+		args: []string{`{"side":"sell","company":"company2","gdsid":"nothere","price":10,"quantity":3}`},
+		code: CodeGoodsNotFound},
+	{name: "does not trade cancelled goods", fn: "open_trade", as: asCarol,
+		setup: cancelGoods,
+		args: []string{`{"side":"sell","company":"company2","gdsid":"{gdsid}","price":10,"quantity":3}`},
+		code: CodeGoodsInactive},
+	{name: "does not offer more than the company holds", fn: "open_trade", as: asCarol,
+		args: []string{`{"side":"sell","company":"company2","gdsid":"{gdsid}","price":10,"quantity":11}`},
+		code: CodeInsufficientQuantity},
+	{name: "counts the units already on offer", fn: "open_trade", as: asCarol,
+		setup: openTrade(asCarol, `{"side":"sell","company":"company2","gdsid":"{gdsid}","price":10,"quantity":8}`),
+		args: []string{`{"side":"sell","company":"company2","gdsid":"{gdsid}","price":10,"quantity":3}`},
+		code: CodeInsufficientQuantity},
+	{name: "needs a registered company", fn: "open_trade", as: asAdmin,
+		args: []string{`{"side":"sell","company":"ghost","gdsid":"{gdsid}","price":10,"quantity":3}`},
+		code: CodeCompanyNotFound},
+	{name: "needs an active company", fn: "open_trade", as: asAdmin,
+		args: []string{`{"side":"sell","company":"company3","gdsid":"{gdsid}","price":10,"quantity":3}`},
+		code: CodeCompanySuspended},
+	{name: "needs the bidder's account", fn: "open_trade", as: asDave,
+		args: []string{`{"side":"buy","company":"company4","gdsid":"{gdsid}","price":10,"quantity":3}`},
+		code: CodeAccountNotFound},
+	{name: "needs the bidder to afford the goods", fn: "open_trade", as: asAlice,
+		args: []string{`{"side":"buy","company":"company1","gdsid":"{gdsid}","price":100,"quantity":3}`},
+		code: CodeInsufficientFunds},
+	{name: "counts the money already bid", fn: "open_trade", as: asAlice,
+		setup: openTrade(asAlice, `{"side":"buy","company":"company1","gdsid":"{gdsid}","price":100,"quantity":1}`),
+		args: []string{`{"side":"buy","company":"company1","gdsid":"{gdsid}","price":100,"quantity":1}`},
+		code: CodeInsufficientFunds},
+	{name: "does not open expired trades", fn: "open_trade", as: asCarol,
+		args: []string{`{"side":"sell","company":"company2","gdsid":"{gdsid}","price":10,"quantity":3,"expiry":1}`},
+		code: CodeInvalidArgument},
+
+	{name: "withdraws the company's offer", fn: "cancel_trade", as: asCarol,
+		setup: openSell,
+		args: []string{"{trade}", "company2"},
+		check: func(l *testLedger, result []byte) {
+			if trades := openTrades(l); len(trades) != 0 {
+				l.t.Errorf("open trades are %+v", trades)
+			}
+		}},
+	{name: "needs an open trade", fn: "cancel_trade", as: asCarol,
+		args: []string{"nope", "company2"},
+		code: CodeTradeNotFound},
+	{name: "needs the company that opened it", fn: "cancel_trade", as: asAlice,
+		setup: openSell,
+		args: []string{"{trade}", "company1"},
+		code: CodeNotOwner},
+
+	{name: "buys part of an offer", fn: "accept_trade", as: asAlice,
+		setup: openSell,
+		args: []string{"{trade}", "company1", "2"},
+		event: EventGoodsSold,
+		check: func(l *testLedger, result []byte) {
+			checkOwners(l, l.vars["gdsid"], owners(Owner{"company2", 8}, Owner{"company1", 2}))
+			checkBalance(l, "company1", 80)
+			checkBalance(l, "company2", 120)
+			if trades := openTrades(l); len(trades) != 1 || trades[0].Quantity != 1 {
+				l.t.Errorf("open trades are %+v, want 1 chair left", trades)
+			}
+		}},
+	{name: "takes the whole offer without a quantity", fn: "accept_trade", as: asAlice,
+		setup: openSell,
+		args: []string{"{trade}", "company1"},
+		event: EventGoodsSold,
+		check: func(l *testLedger, result []byte) {
+			checkOwners(l, l.vars["gdsid"], owners(Owner{"company2", 7}, Owner{"company1", 3}))
+			if trades := openTrades(l); len(trades) != 0 {
+				l.t.Errorf("open trades are %+v", trades)
+			}
+		}},
+	{name: "sells to a bid", fn: "accept_trade", as: asCarol,
+		setup: openBuy,
+		args: []string{"{trade}", "company2"},
+		event: EventGoodsSold,
+		check: func(l *testLedger, result []byte) {
+			checkOwners(l, l.vars["gdsid"], owners(Owner{"company2", 8}, Owner{"company1", 2}))
+			checkBalance(l, "company1", 80)
+		}},
+	{name: "needs an open trade", fn: "accept_trade", as: asAlice,
+		args: []string{"nope", "company1"},
+		code: CodeTradeNotFound},
+	{name: "does not take expired trades", fn: "accept_trade", as: asAlice,
+		setup: func(l *testLedger) {
+			expiry := testTime.Add(time.Hour).UnixNano() / 1e6
+			openTrade(asCarol, `{"side":"sell","company":"company2","gdsid":"{gdsid}","price":10,"quantity":3,"expiry":`+
+				strconv.FormatInt(expiry, 10)+`}`)(l)
+			SetClock(FixedClock{Time: testTime.Add(2 * time.Hour)})
+		},
+		args: []string{"{trade}", "company1"},
+		code: CodeTradeNotFound},
+	{name: "needs the goods", fn: "accept_trade", as: asAlice,
+		setup: func(l *testLedger) {
+			openSell(l)
+			delete(l.stub.State, compositeKey(gdsidObjectType, l.vars["gdsid"]))
+		},
+		args: []string{"{trade}", "company1"},
+		code: CodeGoodsNotFound},
+	{name: "does not sell cancelled goods", fn: "accept_trade", as: asAlice,
+		setup: func(l *testLedger) {
+			openSell(l)
+			cancelGoods(l)
+		},
+		args: []string{"{trade}", "company1"},
+		code: CodeGoodsInactive},
+	{name: "needs the seller to own the goods", fn: "accept_trade", as: asDave,
+		setup: openBuy,
+		args: []string{"{trade}", "company4"},
+		code: CodeNotOwner},
+	{name: "does not take more than is offered", fn: "accept_trade", as: asAlice,
+		setup: openSell,
+		args: []string{"{trade}", "company1", "5"},
+		code: CodeInsufficientQuantity},
+	{name: "needs the buyer's account", fn: "accept_trade", as: asDave,
+		setup: openSell,
+		args: []string{"{trade}", "company4"},
+		code: CodeAccountNotFound},
+	{name: "needs the buyer to afford the goods", fn: "accept_trade", as: asAlice,
+		setup: openTrade(asCarol, `{"side":"sell","company":"company2","gdsid":"{gdsid}","price":1000,"quantity":3}`),
+		args: []string{"{trade}", "company1"},
+		code: CodeInsufficientFunds},
+	{name: "needs a registered company", fn: "accept_trade", as: asAdmin,
+		setup: openSell,
+		args: []string{"{trade}", "ghost"},
+		code: CodeCompanyNotFound},
+	{name: "needs an active company", fn: "accept_trade", as: asAdmin,
+		setup: openSell,
+		args: []string{"{trade}", "company3"},
+		code: CodeCompanySuspended},
+	{name: "does not accept the company's own trade", fn: "accept_trade", as: asCarol,
+		setup: openSell,
+		args: []string{"{trade}", "company2"},
+		code: CodeInvalidArgument},
+
+	{name: "lists the open trades", fn: "list_open_trades", as: asAnonymous,
+		setup: openSell,
+		check: func(l *testLedger, result []byte) {
+			if trades := openTrades(l); len(trades) != 1 || trades[0].ID != l.vars["trade"] {
+				l.t.Errorf("open trades are %+v", trades)
+			}
+		}},
+	{name: "lists the open trades of one goods record", fn: "list_open_trades", as: asAnonymous,
+		setup: openSell,
+		args: []string{"other"},
+		check: func(l *testLedger, result []byte) {
+			if !strings.Contains(string(result), `"open_trades":[]`) {
+				l.t.Errorf("open trades of other are %s", result)
+			}
+		}},
+})
+
+// TestListOpenTradesEmpty - no open trades is a list of none, not null
+func TestListOpenTradesEmpty(t *testing.T) {
+	l := newFixture(t)
+	if result := string(l.must(asAnonymous, "list_open_trades")); !strings.Contains(result, `"open_trades":[]`) {
+		t.Errorf("list_open_trades returned %s", result)
+	}
+}
+
+func TestTradeFunctions(t *testing.T) {
+	runCases(t, tradeCases)
+}
+
+func TestFindTrade(t *testing.T) {
+	trades := AllTrades{OpenTrades: []Trade{{ID: "t1", Side: "sell"}, {ID: "t2", Side: "buy"}}}
+	for id, want := range map[string]int{"t1": 0, "t2": 1, "t3": -1, "": -1} {
+		if got := findTrade(trades, id); got != want {
+			t.Errorf("findTrade(%q) = %d, want %d", id, got, want)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		 http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/celeC/Bien-Chaincode/bien"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// The chaincode itself lives in the bien package, so the local tools can run it too.
+// This is what gets deployed to the peers.
+func main() {
+	shim.SetLoggingLevel(shim.LogInfo)
+	err := shim.Start(new(bien.BienChaincode))
+	if err != nil {
+		fmt.Printf("Error starting BienChaincode chaincode: %s", err)
+	}
+}
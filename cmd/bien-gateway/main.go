@@ -0,0 +1,332 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		 http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// bien-gateway serves the chaincode over HTTP on an in-memory ledger kept in a local
+// file, so front ends can be built and tried without a Fabric network.
+//
+//	POST /invoke/{fn}	body ["arg1", "arg2"] or {"args": [...]}, objects in args are sent as their JSON
+//	GET  /query/{fn}	?arg=arg1&arg=arg2
+//	GET  /goods/{id}	goods by GDSID, GTIN or SSCC
+//	GET  /events		?since=n, the chaincode events from the n-th on, see describe_events
+//
+// Requests are sent as the company in the X-Bien-Company header holding the roles in
+// X-Bien-Roles (issuer,buyer without it), and unsigned without a company. Only a gateway
+// started with -allow-admin sends requests as the local admin, those that ask for it with
+// X-Bien-Roles: admin. It listens on localhost unless -addr says otherwise.
+//
+// Answers are {"txId": "...", "result": ...}, or {"error": {"code": "...", "message": "..."}}
+// with a status that follows the error code: 401 and 403 when the caller may not call the
+// function, 404 for anything not found, 409 for conflicts, 500 when the ledger failed and
+// 400 for everything else.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/celeC/Bien-Chaincode/bien"
+	"github.com/celeC/Bien-Chaincode/mockstub"
+)
+
+// adminName - common name of the local admin, the gateway deploys new ledgers with it
+const adminName = "admin"
+
+type gateway struct {
+	mu         sync.Mutex
+	stub       *mockstub.MockStub
+	ledger     string
+	allowAdmin bool
+}
+
+type response struct {
+	TxID   string          `json:"txId,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *bien.Error     `json:"error,omitempty"`
+}
+
+func main() {
+	addr := flag.String("addr", "localhost:8080", "address to listen on")
+	ledger := flag.String("ledger", "bien-ledger.json", "file the ledger is kept in")
+	initArg := flag.String("init", "1", "argument of Init when the ledger file does not exist yet")
+	allowAdmin := flag.Bool("allow-admin", false, "send requests with X-Bien-Roles: admin as the local admin")
+	flag.Parse()
+
+	g := &gateway{stub: mockstub.NewMockStub("bien", new(bien.BienChaincode)), ledger: *ledger, allowAdmin: *allowAdmin}
+	err := g.stub.Load(g.ledger)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(g.stub.State) == 0 {
+		err = g.deploy(*initArg)
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = g.stub.Save(g.ledger)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	http.HandleFunc("/invoke/", g.invoke)
+	http.HandleFunc("/query/", g.query)
+	http.HandleFunc("/goods/", g.goods)
+	http.HandleFunc("/events", g.events)
+	fmt.Printf("bien-gateway listening on %s, ledger in %s\n", *addr, g.ledger)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
+
+// invoke - POST /invoke/{fn}, the ledger file is written after every successful invoke
+func (g *gateway) invoke(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		reply(w, http.StatusMethodNotAllowed, response{Error: &bien.Error{Code: bien.CodeInvalidArgument,
+			Message: "Invokes must be POSTed"}})
+		return
+	}
+	fn := strings.TrimPrefix(r.URL.Path, "/invoke/")
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		reply(w, http.StatusBadRequest, response{Error: &bien.Error{Code: bien.CodeInvalidArgument,
+			Message: "Error reading request body"}})
+		return
+	}
+	args, err := parseArgs(body)
+	if err != nil {
+		reply(w, http.StatusBadRequest, response{Error: &bien.Error{Code: bien.CodeInvalidArgument,
+			Message: err.Error()}})
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	err = g.setCaller(r)
+	if err != nil {
+		refuse(w, err)
+		return
+	}
+	result, err := g.stub.MockInvoke(fn, args)
+	if err == nil {
+		err = g.stub.Save(g.ledger)
+	}
+	g.answer(w, result, err)
+}
+
+// query - GET /query/{fn}?arg=...&arg=...
+func (g *gateway) query(w http.ResponseWriter, r *http.Request) {
+	fn := strings.TrimPrefix(r.URL.Path, "/query/")
+	args := r.URL.Query()["arg"]
+	if args == nil {
+		args = []string{}
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	err := g.setCaller(r)
+	if err != nil {
+		refuse(w, err)
+		return
+	}
+	result, err := g.stub.MockQuery(fn, args)
+	g.answer(w, result, err)
+}
+
+// goods - GET /goods/{id}
+func (g *gateway) goods(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/goods/")
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	err := g.setCaller(r)
+	if err != nil {
+		refuse(w, err)
+		return
+	}
+	if id == "" {
+		result, err := g.stub.MockQuery("GetAllgoods", []string{})
+		g.answer(w, result, err)
+		return
+	}
+	result, err := g.stub.MockQuery("GetGD", []string{id})
+	g.answer(w, result, err)
+}
+
+// event is a chaincode event as /events lists it, seq is its position in the ledger's event log
+type event struct {
+	Seq     int             `json:"seq"`
+	TxID    string          `json:"txId"`
+	Name    string          `json:"name"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// events - GET /events?since=n, subscribers poll it with the seq after the last event they saw
+func (g *gateway) events(w http.ResponseWriter, r *http.Request) {
+	since := 0
+	if s := r.URL.Query().Get("since"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			reply(w, http.StatusBadRequest, response{Error: &bien.Error{Code: bien.CodeInvalidArgument,
+				Message: "since must be a non-negative integer", Arg: "since"}})
+			return
+		}
+		since = n
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	events := []event{}
+	for i := since; i < len(g.stub.Events); i++ {
+		e := g.stub.Events[i]
+		events = append(events, event{Seq: i, TxID: e.TxID, Name: e.Name, Payload: asJSON(e.Payload)})
+	}
+	result, err := json.Marshal(&events)
+	if err != nil {
+		reply(w, http.StatusInternalServerError, response{Error: &bien.Error{Code: bien.CodeInternal,
+			Message: "Error marshalling events"}})
+		return
+	}
+	reply(w, http.StatusOK, response{Result: result})
+}
+
+// deploy - runs Init on a new ledger as the local admin, Init binds the deployer as the ledger's admin
+func (g *gateway) deploy(value string) error {
+	cert, err := mockstub.NewCertificate(adminName)
+	if err != nil {
+		return err
+	}
+	g.stub.SetCaller(cert, nil)
+	_, err = g.stub.MockInit("init", []string{value})
+	return err
+}
+
+// setCaller - signs the next transaction as the company of the request headers, as the local
+// admin when the headers ask for it and the gateway allows it, or not at all
+func (g *gateway) setCaller(r *http.Request) error {
+	company := r.Header.Get("X-Bien-Company")
+	roles := r.Header.Get("X-Bien-Roles")
+	name := company
+	attributes := map[string]string{"company": company, "roles": bien.RoleIssuer + "," + bien.RoleBuyer}
+	if roles != "" {
+		attributes["roles"] = roles
+	}
+	admin := false
+	for _, role := range strings.Split(roles, ",") {
+		if strings.TrimSpace(role) == bien.RoleAdmin {
+			admin = true
+		}
+	}
+	if admin && !g.allowAdmin {
+		return &bien.Error{Code: bien.CodeForbidden, Message: "This gateway does not send requests as admin, start it with -allow-admin"}
+	}
+	if company == "" && !admin {
+		g.stub.SetCaller(nil, nil)
+		return nil
+	}
+	if company == "" {
+		name = adminName
+		attributes = nil
+	}
+	cert, err := mockstub.NewCertificate(name)
+	if err != nil {
+		return err
+	}
+	g.stub.SetCaller(cert, attributes)
+	return nil
+}
+
+// refuse - answers a request turned away before a transaction ran, so without a txId
+func refuse(w http.ResponseWriter, err error) {
+	e := bien.ParseError(err)
+	reply(w, httpStatus(e.Code), response{Error: e})
+}
+
+func (g *gateway) answer(w http.ResponseWriter, result []byte, err error) {
+	if err != nil {
+		e := bien.ParseError(err)
+		reply(w, httpStatus(e.Code), response{TxID: g.stub.GetTxID(), Error: e})
+		return
+	}
+	reply(w, http.StatusOK, response{TxID: g.stub.GetTxID(), Result: asJSON(result)})
+}
+
+// httpStatus - the status an error code is answered with
+func httpStatus(code string) int {
+	switch code {
+	case bien.CodeUnauthenticated:
+		return http.StatusUnauthorized
+	case bien.CodeForbidden:
+		return http.StatusForbidden
+	case bien.CodeUnknownFunction, bien.CodeGoodsNotFound, bien.CodeAccountNotFound, bien.CodeTradeNotFound,
+		bien.CodeCompanyNotFound, bien.CodeKeyNotFound:
+		return http.StatusNotFound
+	case bien.CodeGoodsExists, bien.CodeAccountExists, bien.CodeCompanyExists, bien.CodeIdentifierTaken:
+		return http.StatusConflict
+	case bien.CodeLedger, bien.CodeCorruptRecord, bien.CodeUnsupportedSchema, bien.CodeInternal:
+		return http.StatusInternalServerError
+	}
+	return http.StatusBadRequest
+}
+
+// parseArgs - the argument list of an invoke body, JSON values other than strings are passed as their JSON text
+func parseArgs(body []byte) ([]string, error) {
+	if len(strings.TrimSpace(string(body))) == 0 {
+		return []string{}, nil
+	}
+	var raw []json.RawMessage
+	err := json.Unmarshal(body, &raw)
+	if err != nil {
+		var wrapped struct {
+			Args []json.RawMessage `json:"args"`
+		}
+		err = json.Unmarshal(body, &wrapped)
+		if err != nil {
+			return nil, errors.New("Body must be a JSON array of arguments or an object with args")
+		}
+		raw = wrapped.Args
+	}
+
+	args := []string{}
+	for _, arg := range raw {
+		var s string
+		if json.Unmarshal(arg, &s) == nil {
+			args = append(args, s)
+		} else {
+			args = append(args, string(arg))
+		}
+	}
+	return args, nil
+}
+
+// asJSON - chaincode results are mostly JSON already, anything else is sent as a string
+func asJSON(result []byte) json.RawMessage {
+	if len(result) == 0 {
+		return nil
+	}
+	var v interface{}
+	if json.Unmarshal(result, &v) == nil {
+		return result
+	}
+	quoted, _ := json.Marshal(string(result))
+	return quoted
+}
+
+func reply(w http.ResponseWriter, status int, resp response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(&resp)
+}
@@ -0,0 +1,233 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		 http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/celeC/Bien-Chaincode/bien"
+	"github.com/celeC/Bien-Chaincode/mockstub"
+)
+
+// newTestGateway - a gateway started with -allow-admin on an initialized ledger kept in a
+// temporary file, with company1 and company2 registered and nothing saved yet
+func newTestGateway(t *testing.T) *gateway {
+	g := &gateway{stub: mockstub.NewMockStub("bien", new(bien.BienChaincode)),
+		ledger: filepath.Join(t.TempDir(), "ledger.json"), allowAdmin: true}
+	err := g.deploy("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, company := range []string{
+		`{"id":"company1","legalName":"Company One Ltd","shortCode":"COMP01","roles":["issuer","buyer","shipper"]}`,
+		`{"id":"company2","legalName":"Company Two Ltd","shortCode":"COMPAN","roles":["issuer","buyer","shipper"]}`,
+	} {
+		if status, resp := send(t, g.invoke, "POST", "/invoke/register_company", "["+company+"]"); status != http.StatusOK {
+			t.Fatalf("register_company answered %d %+v", status, resp)
+		}
+	}
+	os.Remove(g.ledger)
+	return g
+}
+
+// send - one request to a handler as the local admin, the status and decoded answer it gave
+func send(t *testing.T, handler http.HandlerFunc, method string, target string, body string) (int, response) {
+	t.Helper()
+	return sendAs(t, "", bien.RoleAdmin, handler, method, target, body)
+}
+
+// sendAs - send with the company and roles headers set, unless they are ""
+func sendAs(t *testing.T, company string, roles string, handler http.HandlerFunc, method string, target string, body string) (int, response) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	if company != "" {
+		r.Header.Set("X-Bien-Company", company)
+	}
+	if roles != "" {
+		r.Header.Set("X-Bien-Roles", roles)
+	}
+	handler(w, r)
+	var resp response
+	err := json.Unmarshal(w.Body.Bytes(), &resp)
+	if err != nil {
+		t.Fatalf("%s %s answered %s: %v", method, target, w.Body, err)
+	}
+	return w.Code, resp
+}
+
+func TestParseArgs(t *testing.T) {
+	for body, want := range map[string][]string{
+		``:                                  {},
+		`["a", "b"]`:                        {"a", "b"},
+		`{"args": ["a"]}`:                   {"a"},
+		`[{"name":"chair"}, 3, "company1"]`: {`{"name":"chair"}`, "3", "company1"},
+	} {
+		if args, err := parseArgs([]byte(body)); err != nil || !reflect.DeepEqual(args, want) {
+			t.Errorf("parseArgs(%q) = %q, %v, want %q", body, args, err, want)
+		}
+	}
+	if _, err := parseArgs([]byte(`"a"`)); err == nil {
+		t.Error("parseArgs accepted a body that is neither a list nor an object with args")
+	}
+}
+
+func TestGateway(t *testing.T) {
+	g := newTestGateway(t)
+
+	status, resp := send(t, g.invoke, "POST", "/invoke/add_goods",
+		`[{"name":"chair","price":12.5,"issuer":"company2","quantity":10}]`)
+	if status != http.StatusOK || resp.TxID == "" {
+		t.Fatalf("add_goods answered %d %+v", status, resp)
+	}
+	var gdsid string
+	if err := json.Unmarshal(resp.Result, &gdsid); err != nil {
+		t.Fatalf("add_goods returned %s: %v", resp.Result, err)
+	}
+	if _, err := os.Stat(g.ledger); err != nil {
+		t.Errorf("the ledger was not saved after the invoke: %v", err)
+	}
+
+	status, resp = send(t, g.goods, "GET", "/goods/"+gdsid, "")
+	var goods bien.Goods
+	if status != http.StatusOK || json.Unmarshal(resp.Result, &goods) != nil || goods.Name != "chair" {
+		t.Errorf("/goods/%s answered %d %+v", gdsid, status, resp)
+	}
+
+	status, resp = send(t, g.query, "GET", "/query/validate_gdsid?arg="+gdsid, "")
+	var info bien.GDSIDInfo
+	if status != http.StatusOK || json.Unmarshal(resp.Result, &info) != nil || !info.Valid {
+		t.Errorf("validate_gdsid answered %d %+v", status, resp)
+	}
+}
+
+func TestGatewayErrors(t *testing.T) {
+	g := newTestGateway(t)
+	if status, _ := send(t, g.invoke, "GET", "/invoke/add_goods", ""); status != http.StatusMethodNotAllowed {
+		t.Errorf("GET of an invoke answered %d", status)
+	}
+	if status, resp := send(t, g.invoke, "POST", "/invoke/add_goods", `"chair"`); status != http.StatusBadRequest || resp.Error == nil {
+		t.Errorf("an invoke without an argument list answered %d %+v", status, resp)
+	}
+	status, resp := send(t, g.invoke, "POST", "/invoke/add_goods", `[{"name":"chair"}]`)
+	if status != http.StatusBadRequest || resp.Error == nil || resp.Error.Code != bien.CodeInvalidArgument || resp.Result != nil {
+		t.Errorf("a failing invoke answered %d %+v", status, resp)
+	}
+	if _, err := os.Stat(g.ledger); !os.IsNotExist(err) {
+		t.Error("the ledger was saved after a failed invoke")
+	}
+	status, resp = send(t, g.invoke, "POST", "/invoke/add_goods", `[{"name":"chair","issuer":"ghost"}]`)
+	if status != http.StatusNotFound || resp.Error == nil || resp.Error.Code != bien.CodeCompanyNotFound {
+		t.Errorf("issuing for an unregistered company answered %d %+v", status, resp)
+	}
+
+	status, resp = send(t, g.goods, "GET", "/goods/COMPANLG0000015", "")
+	if status != http.StatusNotFound || resp.Error == nil || resp.Error.Code != bien.CodeGoodsNotFound {
+		t.Errorf("unknown goods answered %d %+v", status, resp)
+	}
+	status, resp = send(t, g.query, "GET", "/query/fly", "")
+	if status != http.StatusNotFound || resp.Error == nil || resp.Error.Code != bien.CodeUnknownFunction {
+		t.Errorf("an unknown query answered %d %+v", status, resp)
+	}
+}
+
+func TestGatewayCaller(t *testing.T) {
+	g := newTestGateway(t)
+	status, resp := sendAs(t, "company2", "", g.invoke, "POST", "/invoke/add_goods",
+		`[{"name":"chair","price":12.5,"issuer":"company2","quantity":10}]`)
+	if status != http.StatusOK {
+		t.Fatalf("company2 issuing its goods answered %d %+v", status, resp)
+	}
+	var gdsid string
+	if err := json.Unmarshal(resp.Result, &gdsid); err != nil {
+		t.Fatal(err)
+	}
+
+	status, resp = sendAs(t, "company1", "", g.invoke, "POST", "/invoke/set_price", `["`+gdsid+`", 15]`)
+	if resp.Error == nil || resp.Error.Code != bien.CodeForbidden {
+		t.Errorf("company1 repricing the goods of company2 answered %d %+v", status, resp)
+	}
+	if status, resp = sendAs(t, "company2", "", g.invoke, "POST", "/invoke/set_price", `["`+gdsid+`", 15]`); status != http.StatusOK {
+		t.Errorf("company2 repricing its goods answered %d %+v", status, resp)
+	}
+}
+
+func TestGatewayAdmin(t *testing.T) {
+	g := newTestGateway(t)
+	status, resp := sendAs(t, "", "", g.invoke, "POST", "/invoke/add_goods", `[{"name":"chair","issuer":"company2"}]`)
+	if status != http.StatusUnauthorized || resp.Error == nil || resp.Error.Code != bien.CodeUnauthenticated {
+		t.Errorf("an invoke without a company answered %d %+v", status, resp)
+	}
+	if status, resp = sendAs(t, "", "", g.query, "GET", "/query/list_goods", ""); status != http.StatusOK {
+		t.Errorf("a query without a company answered %d %+v", status, resp)
+	}
+
+	g.allowAdmin = false
+	status, resp = send(t, g.invoke, "POST", "/invoke/add_goods", `[{"name":"chair","issuer":"company2"}]`)
+	if status != http.StatusForbidden || resp.Error == nil || resp.Error.Code != bien.CodeForbidden || resp.TxID != "" {
+		t.Errorf("an admin request to a gateway without -allow-admin answered %d %+v", status, resp)
+	}
+	status, resp = sendAs(t, "company2", "issuer,admin", g.invoke, "POST", "/invoke/add_goods", `[{"name":"chair","issuer":"company2"}]`)
+	if status != http.StatusForbidden || resp.Error == nil || resp.Error.Code != bien.CodeForbidden {
+		t.Errorf("a company asking for admin answered %d %+v", status, resp)
+	}
+}
+
+func TestGatewayEvents(t *testing.T) {
+	g := newTestGateway(t)
+	for _, name := range []string{"chair", "table"} {
+		if status, resp := send(t, g.invoke, "POST", "/invoke/add_goods", `[{"name":"`+name+`","issuer":"company2"}]`); status != http.StatusOK {
+			t.Fatalf("add_goods answered %d %+v", status, resp)
+		}
+	}
+
+	status, resp := send(t, g.events, "GET", "/events?since=1", "")
+	var events []event
+	if status != http.StatusOK || json.Unmarshal(resp.Result, &events) != nil {
+		t.Fatalf("/events answered %d %+v", status, resp)
+	}
+	if len(events) != 1 || events[0].Seq != 1 || events[0].Name != bien.EventGoodsIssued {
+		t.Fatalf("events since 1 are %+v", events)
+	}
+	var payload bien.GoodsEvent
+	if err := json.Unmarshal(events[0].Payload, &payload); err != nil || payload.TxID != events[0].TxID {
+		t.Errorf("payload of %+v is %+v, %v", events[0], payload, err)
+	}
+
+	if status, resp := send(t, g.events, "GET", "/events?since=-1", ""); status != http.StatusBadRequest || resp.Error == nil {
+		t.Errorf("a negative since answered %d %+v", status, resp)
+	}
+}
+
+func TestHTTPStatus(t *testing.T) {
+	for code, want := range map[string]int{
+		bien.CodeUnauthenticated:   http.StatusUnauthorized,
+		bien.CodeForbidden:         http.StatusForbidden,
+		bien.CodeTradeNotFound:     http.StatusNotFound,
+		bien.CodeAccountExists:     http.StatusConflict,
+		bien.CodeLedger:            http.StatusInternalServerError,
+		bien.CodeInsufficientFunds: http.StatusBadRequest,
+	} {
+		if status := httpStatus(code); status != want {
+			t.Errorf("%s is answered with %d, want %d", code, status, want)
+		}
+	}
+}
@@ -0,0 +1,418 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		 http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// bienctl invokes and queries the chaincode on a local ledger file, building the
+// argument lists from typed flags:
+//
+//	bienctl [-ledger file] [-record script] [-as company [-roles r,r]] [-v] command [flags]
+//	bienctl [-ledger file] -script script
+//
+// Commands are sent as the local admin, or as the company given with -as holding
+// the given roles. init on an empty ledger deploys the chaincode, which makes the
+// caller the ledger's admin. In a script the as command switches the caller for the lines after it.
+//
+// A script holds one command per line, as typed after bienctl; blank lines and
+// lines starting with # are skipped. -record appends every command that succeeded
+// to a script, so a session can be replayed. Without -ledger a script runs on an
+// empty ledger that is thrown away afterwards.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/celeC/Bien-Chaincode/bien"
+	"github.com/celeC/Bien-Chaincode/mockstub"
+)
+
+const usage = `commands:
+  init        -value n
+  write       -key k -value v
+  read        -key k
+  add_goods   -name n -owner company -state new -price p -postage p
+  issue       -name n -issuer company [-quantity q] -price p -postage p   the issuer owns every unit
+  transfer    -gdsid id -from company -to company [-quantity q]
+  GetAllgoods
+  GetGD       -id gdsid|gtin|sscc
+  events      [-since n]                    the chaincode events the ledger emitted, from the n-th on
+  as          [-company c] [-roles r,r]     the caller of the following commands, the admin without -company
+  invoke      function [arg ...]
+  query       function [arg ...]
+`
+
+// defaultRoles are the roles of a company the commands are sent as
+const defaultRoles = bien.RoleIssuer + "," + bien.RoleBuyer
+
+// verbose shows what the chaincode prints while it runs
+var verbose bool
+
+func main() {
+	ledger := flag.String("ledger", "", "ledger file to run against, bien-ledger.json unless a script is given")
+	script := flag.String("script", "", "script of commands to run")
+	record := flag.String("record", "", "script to append every successful command to")
+	as := flag.String("as", "", "company to send the commands as, the local admin without it")
+	roles := flag.String("roles", defaultRoles, "roles of the company given with -as")
+	flag.BoolVar(&verbose, "v", false, "show the chaincode's own output")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: bienctl [flags] command [command flags]")
+		flag.PrintDefaults()
+		fmt.Fprint(os.Stderr, usage)
+	}
+	flag.Parse()
+
+	if *ledger == "" && *script == "" {
+		*ledger = "bien-ledger.json"
+	}
+	stub := mockstub.NewMockStub("bien", new(bien.BienChaincode))
+	err := setCaller(stub, *as, *roles)
+	if err != nil {
+		fail(err)
+	}
+	if *ledger != "" {
+		err := stub.Load(*ledger)
+		if err != nil {
+			fail(err)
+		}
+	}
+
+	if *script != "" {
+		err = runScript(stub, *script)
+	} else {
+		if flag.NArg() == 0 {
+			flag.Usage()
+			os.Exit(2)
+		}
+		err = run(stub, flag.Args())
+		if err == nil && *record != "" {
+			err = recordCommand(*record, callerLine(*as, *roles), flag.Args())
+		}
+	}
+	if err != nil {
+		fail(err)
+	}
+	if *ledger != "" {
+		err = stub.Save(*ledger)
+		if err != nil {
+			fail(err)
+		}
+	}
+}
+
+// runScript - runs every command of a script, stopping at the first that fails
+func runScript(stub *mockstub.MockStub, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		args, err := splitLine(text)
+		if err != nil {
+			return fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		fmt.Println("> " + text)
+		err = run(stub, args)
+		if err != nil {
+			return fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// run - turns one command and its flags into a chaincode call and prints the result
+func run(stub *mockstub.MockStub, args []string) error {
+	command := args[0]
+	fs := flag.NewFlagSet(command, flag.ContinueOnError)
+
+	switch command {
+	case "init":
+		value := fs.String("value", "1", "initial value of the test variable abc")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		return call(stub, false, "init", *value)
+
+	case "write":
+		key := fs.String("key", "", "key to write")
+		value := fs.String("value", "", "value to write")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		return call(stub, false, "write", *key, *value)
+
+	case "read":
+		key := fs.String("key", "", "key to read")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		return call(stub, true, "read", *key)
+
+	case "add_goods":
+		name := fs.String("name", "", "goods name")
+		owner := fs.String("owner", "", "company owning the goods")
+		state := fs.String("state", bien.StateNew, "lifecycle state")
+		price := fs.Float64("price", 0, "price")
+		postage := fs.Float64("postage", 0, "postage")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		return call(stub, false, "add_goods", *name, *owner, *state,
+			strconv.FormatFloat(*price, 'f', -1, 64), strconv.FormatFloat(*postage, 'f', -1, 64))
+
+	case "issue":
+		var goods bien.Goods
+		fs.StringVar(&goods.Name, "name", "", "goods name")
+		fs.StringVar(&goods.Issuer, "issuer", "", "issuing company")
+		fs.IntVar(&goods.Quantity, "quantity", 1, "issued quantity, the issuer owns every unit")
+		fs.Float64Var(&goods.Price, "price", 0, "price")
+		fs.Float64Var(&goods.Postage, "postage", 0, "postage")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		goodsBytes, err := json.Marshal(&goods)
+		if err != nil {
+			return err
+		}
+		return call(stub, false, "add_goods", string(goodsBytes))
+
+	case "transfer":
+		var tr bien.Transaction
+		fs.StringVar(&tr.GDSID, "gdsid", "", "goods to transfer")
+		fs.StringVar(&tr.FromCompany, "from", "", "company giving the goods")
+		fs.StringVar(&tr.ToCompany, "to", "", "company receiving the goods")
+		fs.IntVar(&tr.Quantity, "quantity", 0, "units to move, the whole holding when left out")
+		fs.Float64Var(&tr.Postage, "postage", 0, "postage")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		trBytes, err := json.Marshal(&tr)
+		if err != nil {
+			return err
+		}
+		return call(stub, false, "transfer_goods", string(trBytes))
+
+	case "GetAllgoods":
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		return call(stub, true, "GetAllgoods")
+
+	case "GetGD":
+		id := fs.String("id", "", "GDSID, GTIN or SSCC of the goods")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		return call(stub, true, "GetGD", *id)
+
+	case "events":
+		since := fs.Int("since", 0, "position of the first event to show")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		for i := *since; i < len(stub.Events); i++ {
+			e := stub.Events[i]
+			fmt.Printf("%d %s %s %s\n", i, e.TxID, e.Name, e.Payload)
+		}
+		return nil
+
+	case "as":
+		company := fs.String("company", "", "company to send the following commands as")
+		roles := fs.String("roles", defaultRoles, "roles of the company")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		return setCaller(stub, *company, *roles)
+
+	case "invoke", "query":
+		if len(args) < 2 {
+			return errors.New(command + " needs a function name")
+		}
+		return call(stub, command == "query", args[1], args[2:]...)
+	}
+	return errors.New("Unknown command " + command + "\n" + usage)
+}
+
+// setCaller - signs the following transactions as the company with the roles, or as the local admin
+func setCaller(stub *mockstub.MockStub, company string, roles string) error {
+	name := company
+	attributes := map[string]string{"company": company, "roles": roles}
+	if company == "" {
+		name = "admin"
+		attributes = nil
+	}
+	cert, err := mockstub.NewCertificate(name)
+	if err != nil {
+		return err
+	}
+	stub.SetCaller(cert, attributes)
+	return nil
+}
+
+// call - runs the chaincode function and pretty prints what it returns
+func call(stub *mockstub.MockStub, query bool, function string, args ...string) error {
+	stdout := os.Stdout
+	if !verbose {
+		if devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0); err == nil {
+			os.Stdout = devNull
+			defer devNull.Close()
+		}
+	}
+	var result []byte
+	var err error
+	if query {
+		result, err = stub.MockQuery(function, args)
+	} else if function == "init" && len(stub.State) == 0 {
+		// a new ledger is deployed, Init binds the caller as its admin
+		result, err = stub.MockInit(function, args)
+	} else {
+		result, err = stub.MockInvoke(function, args)
+	}
+	os.Stdout = stdout
+	if err != nil {
+		e := bien.ParseError(err)
+		if e.Arg != "" {
+			return fmt.Errorf("%s (%s): %s", e.Code, e.Arg, e.Message)
+		}
+		return fmt.Errorf("%s: %s", e.Code, e.Message)
+	}
+
+	var pretty bytes.Buffer
+	if json.Indent(&pretty, result, "", "  ") == nil {
+		fmt.Println(pretty.String())
+	} else if len(result) > 0 {
+		fmt.Println(string(result))
+	}
+	return nil
+}
+
+// recordCommand - appends a command to a script, quoting arguments so splitLine reads them back.
+// An as line with the caller goes first unless the script already sends its commands as that caller,
+// so replaying the script calls the chaincode with the same company and roles.
+func recordCommand(path string, caller []string, args []string) error {
+	last, err := lastCallerLine(path)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if args[0] != "as" && quoteLine(caller) != last {
+		_, err = fmt.Fprintln(file, quoteLine(caller))
+		if err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintln(file, quoteLine(args))
+	return err
+}
+
+// callerLine - the as command that sends the following commands as the company and roles given to bienctl
+func callerLine(company string, roles string) []string {
+	if company == "" {
+		return []string{"as"}
+	}
+	return []string{"as", "-company", company, "-roles", roles}
+}
+
+// lastCallerLine - the last as line of a script, "" when it has none or does not exist yet
+func lastCallerLine(path string) (string, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	last := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "as" || strings.HasPrefix(text, "as ") {
+			last = text
+		}
+	}
+	return last, scanner.Err()
+}
+
+// quoteLine - joins words into a script line, quoting the ones splitLine would split or drop
+func quoteLine(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\"'\\#") {
+			arg = strconv.Quote(arg)
+		}
+		quoted[i] = arg
+	}
+	return strings.Join(quoted, " ")
+}
+
+// splitLine - splits a script line into words, double quoted words use Go string escapes
+func splitLine(line string) ([]string, error) {
+	var words []string
+	for {
+		line = strings.TrimLeft(line, " \t")
+		if line == "" {
+			return words, nil
+		}
+		if line[0] != '"' {
+			end := strings.IndexAny(line, " \t")
+			if end < 0 {
+				end = len(line)
+			}
+			words = append(words, line[:end])
+			line = line[end:]
+			continue
+		}
+		end := 1
+		for end < len(line) && line[end] != '"' {
+			if line[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(line) {
+			return nil, errors.New("Unterminated quote")
+		}
+		word, err := strconv.Unquote(line[:end+1])
+		if err != nil {
+			return nil, err
+		}
+		words = append(words, word)
+		line = line[end+1:]
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "bienctl:", err)
+	os.Exit(1)
+}
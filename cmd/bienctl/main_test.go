@@ -0,0 +1,186 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		 http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/celeC/Bien-Chaincode/bien"
+	"github.com/celeC/Bien-Chaincode/mockstub"
+)
+
+func TestSplitLine(t *testing.T) {
+	for line, want := range map[string][]string{
+		"read -key abc":                    {"read", "-key", "abc"},
+		"  write\t-key a   -value b ":      {"write", "-key", "a", "-value", "b"},
+		`issue -name "big chair" -price 3`: {"issue", "-name", "big chair", "-price", "3"},
+		`write -key "a \"b\"" -value ""`:   {"write", "-key", `a "b"`, "-value", ""},
+	} {
+		if words, err := splitLine(line); err != nil || !reflect.DeepEqual(words, want) {
+			t.Errorf("splitLine(%q) = %q, %v, want %q", line, words, err, want)
+		}
+	}
+	if _, err := splitLine(`write -key "abc`); err == nil {
+		t.Error("splitLine accepted an unterminated quote")
+	}
+}
+
+func TestRecordedCommandsReadBack(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.txt")
+	commands := [][]string{
+		{"write", "-key", "a b", "-value", `"quoted"`},
+		{"query", "read", "# not a comment", ""},
+	}
+	for _, args := range commands {
+		if err := recordCommand(path, callerLine("", ""), args); err != nil {
+			t.Fatal(err)
+		}
+	}
+	script, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(script)), "\n")
+	want := append([][]string{{"as"}}, commands...)
+	if len(lines) != len(want) {
+		t.Fatalf("recorded %q, want %d lines", lines, len(want))
+	}
+	for i, line := range lines {
+		if words, err := splitLine(line); err != nil || !reflect.DeepEqual(words, want[i]) {
+			t.Errorf("line %q reads back as %q, %v, want %q", line, words, err, want[i])
+		}
+	}
+}
+
+func TestRecordedCallers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.txt")
+	for _, c := range []struct {
+		company string
+		args    []string
+	}{
+		{"", []string{"init"}},
+		{"company2", []string{"issue", "-name", "chair", "-issuer", "company2"}},
+		{"company2", []string{"GetAllgoods"}},
+		{"", []string{"read", "-key", "abc"}},
+	} {
+		if err := recordCommand(path, callerLine(c.company, "issuer,buyer"), c.args); err != nil {
+			t.Fatal(err)
+		}
+	}
+	script, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `as
+init
+as -company company2 -roles issuer,buyer
+issue -name chair -issuer company2
+GetAllgoods
+as
+read -key abc
+`
+	if string(script) != want {
+		t.Errorf("recorded\n%s\nwant\n%s", script, want)
+	}
+}
+
+func TestRunScript(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script.txt")
+	script := `# company2 issues chairs and sells some to company1
+init -value 1
+invoke register_company "{\"id\":\"company2\",\"legalName\":\"Company Two Ltd\",\"roles\":[\"issuer\",\"buyer\"]}"
+as -company company2
+issue -name chair -issuer company2 -quantity 10 -price 12.5
+as
+invoke write stock "10 chairs"
+`
+	if err := os.WriteFile(path, []byte(script), 0644); err != nil {
+		t.Fatal(err)
+	}
+	stub := mockstub.NewMockStub("bien", new(bien.BienChaincode))
+	if err := setCaller(stub, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := runScript(stub, path); err != nil {
+		t.Fatal(err)
+	}
+	if stock, _ := stub.MockQuery("read", []string{"stock"}); string(stock) != "10 chairs" {
+		t.Errorf("stock is %q", stock)
+	}
+
+	if err := os.WriteFile(path, []byte("as -company company1\ninvoke write stock none\nread -key abc\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := runScript(stub, path); err == nil || !strings.Contains(err.Error(), ":2:") {
+		t.Errorf("a script failing on line 2 returned %v", err)
+	}
+	if stock, _ := stub.MockQuery("read", []string{"stock"}); string(stock) != "10 chairs" {
+		t.Errorf("company1 wrote stock %q without the admin role", stock)
+	}
+}
+
+func TestRunBuildsArguments(t *testing.T) {
+	stub := mockstub.NewMockStub("bien", new(bien.BienChaincode))
+	if err := setCaller(stub, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"init"},
+		{"invoke", "register_company", `{"id":"company2","legalName":"Company Two Ltd","roles":["issuer","buyer"]}`},
+		{"issue", "-name", "chair", "-issuer", "company2", "-quantity", "10", "-price", "12.5", "-postage", "3"},
+	} {
+		if err := run(stub, args); err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+	}
+	all, err := stub.MockQuery("query", []string{"GetAllgoods"})
+	if err != nil || !strings.Contains(string(all), `"name":"chair"`) || !strings.Contains(string(all), `"postage":3`) {
+		t.Errorf("goods after issue are %s, %v", all, err)
+	}
+	if err := run(stub, []string{"issue", "-name", "stool", "-issuer", "company2", "-owner", "company1=3"}); err == nil {
+		t.Error("issue took owners the chaincode does not honour")
+	}
+	if err := run(stub, []string{"transfer", "-gdsid", "nothere", "-from", "company2", "-to", "company1"}); err == nil {
+		t.Error("transfer of unknown goods succeeded")
+	}
+	if err := run(stub, []string{"fly"}); err == nil || !strings.Contains(err.Error(), "Unknown command fly") {
+		t.Errorf("an unknown command returned %v", err)
+	}
+}
+
+func TestInitMakesTheLocalAdmin(t *testing.T) {
+	stub := mockstub.NewMockStub("bien", new(bien.BienChaincode))
+	if err := setCaller(stub, "company1", bien.RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	if err := run(stub, []string{"init"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := setCaller(stub, "company2", bien.RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	if err := run(stub, []string{"invoke", "init", "2"}); err == nil || !strings.Contains(err.Error(), bien.CodeForbidden) {
+		t.Errorf("company2 with the admin role in its certificate ran init again: %v", err)
+	}
+	if err := setCaller(stub, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := run(stub, []string{"invoke", "init", "2"}); err == nil || !strings.Contains(err.Error(), bien.CodeForbidden) {
+		t.Errorf("the local admin ran init on a ledger company1 deployed: %v", err)
+	}
+}
@@ -0,0 +1,387 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		 http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package mockstub runs a chaincode against an in-memory ledger, without a peer.
+// It implements shim.ChaincodeStubInterface with a state map and behaves like a
+// peer where the chaincode can tell the difference: every transaction gets its own
+// ID and timestamp, a failed invoke leaves no writes behind, queries cannot write,
+// and only the last event a transaction sets is emitted.
+package mockstub
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/core/chaincode/shim/crypto/attr"
+)
+
+// Event is a chaincode event emitted by a committed transaction
+type Event struct {
+	TxID    string `json:"txId"`
+	Name    string `json:"name"`
+	Payload []byte `json:"payload"`
+}
+
+// MockStub is the in-memory ledger a chaincode runs against
+type MockStub struct {
+	Name   string
+	State  map[string][]byte
+	Events []Event
+
+	// Clock gives the timestamp of every new transaction, time.Now when nil
+	Clock func() time.Time
+
+	cc       shim.Chaincode
+	txCount  int
+	txID     string
+	txTime   *timestamp.Timestamp
+	readOnly bool
+	args     []string
+	event    *Event
+
+	caller     []byte
+	attributes map[string][]byte
+	metadata   []byte
+}
+
+// NewMockStub - an empty ledger running cc
+func NewMockStub(name string, cc shim.Chaincode) *MockStub {
+	return &MockStub{
+		Name:       name,
+		State:      map[string][]byte{},
+		cc:         cc,
+		attributes: map[string][]byte{},
+	}
+}
+
+// SetCaller - the certificate and certificate attributes the following transactions are sent with
+func (s *MockStub) SetCaller(cert []byte, attributes map[string]string) {
+	s.caller = cert
+	s.attributes = map[string][]byte{}
+	for name, value := range attributes {
+		s.attributes[name] = []byte(value)
+	}
+}
+
+// SetTransient - data passed alongside the arguments without being stored, the chaincode reads it as caller metadata
+func (s *MockStub) SetTransient(data []byte) {
+	s.metadata = data
+}
+
+// MockInit - runs Init as one transaction
+func (s *MockStub) MockInit(function string, args []string) ([]byte, error) {
+	return s.transact(false, function, args, s.cc.Init)
+}
+
+// MockInvoke - runs Invoke as one transaction, its writes are kept only if it succeeds
+func (s *MockStub) MockInvoke(function string, args []string) ([]byte, error) {
+	return s.transact(false, function, args, s.cc.Invoke)
+}
+
+// MockQuery - runs Query, which may read but not write the state
+func (s *MockStub) MockQuery(function string, args []string) ([]byte, error) {
+	return s.transact(true, function, args, s.cc.Query)
+}
+
+func (s *MockStub) transact(readOnly bool, function string, args []string,
+	fn func(shim.ChaincodeStubInterface, string, []string) ([]byte, error)) ([]byte, error) {
+
+	s.txCount++
+	s.txID = fmt.Sprintf("%s-tx%06d", s.Name, s.txCount)
+	now := time.Now()
+	if s.Clock != nil {
+		now = s.Clock()
+	}
+	s.txTime = &timestamp.Timestamp{Seconds: now.Unix(), Nanos: int32(now.Nanosecond())}
+	s.readOnly = readOnly
+	s.args = append([]string{function}, args...)
+	s.event = nil
+
+	snapshot := make(map[string][]byte, len(s.State))
+	for key, value := range s.State {
+		snapshot[key] = value
+	}
+
+	result, err := fn(s, function, args)
+	if err != nil {
+		// the peer throws away the write set of a failed transaction
+		s.State = snapshot
+		return nil, err
+	}
+	if s.event != nil {
+		s.Events = append(s.Events, *s.event)
+	}
+	return result, nil
+}
+
+// ledgerFile is how Save writes the ledger, values are kept as text so the file can be read and edited
+type ledgerFile struct {
+	Name    string            `json:"name"`
+	TxCount int               `json:"txCount"`
+	State   map[string]string `json:"state"`
+	Events  []Event           `json:"events"`
+}
+
+// Save - writes the state, events and transaction count to a file
+func (s *MockStub) Save(path string) error {
+	ledger := ledgerFile{Name: s.Name, TxCount: s.txCount, State: map[string]string{}, Events: s.Events}
+	for key, value := range s.State {
+		ledger.State[key] = string(value)
+	}
+	ledgerBytes, err := json.MarshalIndent(&ledger, "", "  ")
+	if err != nil {
+		return errors.New("Error marshalling ledger")
+	}
+	return ioutil.WriteFile(path, ledgerBytes, 0644)
+}
+
+// Load - replaces the ledger with the one saved in a file, a missing file leaves it empty
+func (s *MockStub) Load(path string) error {
+	ledgerBytes, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var ledger ledgerFile
+	err = json.Unmarshal(ledgerBytes, &ledger)
+	if err != nil {
+		return errors.New("Ledger file " + path + " is corrupt")
+	}
+	s.txCount = ledger.TxCount
+	s.Events = ledger.Events
+	s.State = map[string][]byte{}
+	for key, value := range ledger.State {
+		s.State[key] = []byte(value)
+	}
+	return nil
+}
+
+// NewCertificate - a self-signed certificate in PEM for the given common name, to use with SetCaller
+func NewCertificate(commonName string) ([]byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * 365 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), nil
+}
+
+// The shim.ChaincodeStubInterface methods
+
+func (s *MockStub) GetArgs() [][]byte {
+	args := make([][]byte, len(s.args))
+	for i, arg := range s.args {
+		args[i] = []byte(arg)
+	}
+	return args
+}
+
+func (s *MockStub) GetStringArgs() []string {
+	return s.args
+}
+
+func (s *MockStub) GetTxID() string {
+	return s.txID
+}
+
+func (s *MockStub) GetTxTimestamp() (*timestamp.Timestamp, error) {
+	if s.txTime == nil {
+		return nil, errors.New("No transaction is running")
+	}
+	return s.txTime, nil
+}
+
+func (s *MockStub) GetState(key string) ([]byte, error) {
+	return s.State[key], nil
+}
+
+func (s *MockStub) PutState(key string, value []byte) error {
+	if s.readOnly {
+		return errors.New("Queries cannot write state")
+	}
+	if key == "" {
+		return errors.New("Key must not be empty")
+	}
+	s.State[key] = value
+	return nil
+}
+
+func (s *MockStub) DelState(key string) error {
+	if s.readOnly {
+		return errors.New("Queries cannot write state")
+	}
+	delete(s.State, key)
+	return nil
+}
+
+// RangeQueryState - the keys from startKey up to and including endKey, in key order, as the
+// peer's range scan returns them. An empty endKey leaves the range open.
+func (s *MockStub) RangeQueryState(startKey, endKey string) (shim.StateRangeQueryIteratorInterface, error) {
+	var keys []string
+	for key := range s.State {
+		if strings.Compare(key, startKey) >= 0 && (endKey == "" || strings.Compare(key, endKey) <= 0) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return &rangeIterator{stub: s, keys: keys}, nil
+}
+
+func (s *MockStub) GetCallerCertificate() ([]byte, error) {
+	return s.caller, nil
+}
+
+func (s *MockStub) GetCallerMetadata() ([]byte, error) {
+	return s.metadata, nil
+}
+
+func (s *MockStub) ReadCertAttribute(attributeName string) ([]byte, error) {
+	value, ok := s.attributes[attributeName]
+	if !ok {
+		return nil, errors.New("Caller certificate has no attribute " + attributeName)
+	}
+	return value, nil
+}
+
+func (s *MockStub) VerifyAttribute(attributeName string, attributeValue []byte) (bool, error) {
+	value, ok := s.attributes[attributeName]
+	return ok && bytes.Equal(value, attributeValue), nil
+}
+
+func (s *MockStub) VerifyAttributes(attrs ...*attr.Attribute) (bool, error) {
+	for _, a := range attrs {
+		ok, err := s.VerifyAttribute(a.Name, a.Value)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func (s *MockStub) VerifySignature(certificate, signature, message []byte) (bool, error) {
+	return false, errors.New("Signatures are not verified by the mock stub")
+}
+
+// SetEvent - like on a peer, only the last event set by a transaction is emitted
+func (s *MockStub) SetEvent(name string, payload []byte) error {
+	if name == "" {
+		return errors.New("Event name must not be empty")
+	}
+	s.event = &Event{TxID: s.txID, Name: name, Payload: payload}
+	return nil
+}
+
+func (s *MockStub) GetBinding() ([]byte, error) {
+	return []byte(s.txID), nil
+}
+
+func (s *MockStub) GetPayload() ([]byte, error) {
+	return json.Marshal(s.args)
+}
+
+func (s *MockStub) InvokeChaincode(chaincodeName string, args [][]byte) ([]byte, error) {
+	return nil, errors.New("The mock stub cannot call other chaincodes")
+}
+
+func (s *MockStub) QueryChaincode(chaincodeName string, args [][]byte) ([]byte, error) {
+	return nil, errors.New("The mock stub cannot call other chaincodes")
+}
+
+// The chaincode keeps everything in key/value state, tables are not supported
+
+var errNoTables = errors.New("The mock stub does not support tables")
+
+func (s *MockStub) CreateTable(name string, columnDefinitions []*shim.ColumnDefinition) error {
+	return errNoTables
+}
+
+func (s *MockStub) GetTable(tableName string) (*shim.Table, error) {
+	return nil, errNoTables
+}
+
+func (s *MockStub) DeleteTable(tableName string) error {
+	return errNoTables
+}
+
+func (s *MockStub) InsertRow(tableName string, row shim.Row) (bool, error) {
+	return false, errNoTables
+}
+
+func (s *MockStub) ReplaceRow(tableName string, row shim.Row) (bool, error) {
+	return false, errNoTables
+}
+
+func (s *MockStub) GetRow(tableName string, key []shim.Column) (shim.Row, error) {
+	return shim.Row{}, errNoTables
+}
+
+func (s *MockStub) GetRows(tableName string, key []shim.Column) (<-chan shim.Row, error) {
+	return nil, errNoTables
+}
+
+func (s *MockStub) DeleteRow(tableName string, key []shim.Column) error {
+	return errNoTables
+}
+
+// rangeIterator walks the keys found when the range query ran, reading each value as it goes
+type rangeIterator struct {
+	stub   *MockStub
+	keys   []string
+	next   int
+	closed bool
+}
+
+func (it *rangeIterator) HasNext() bool {
+	return !it.closed && it.next < len(it.keys)
+}
+
+func (it *rangeIterator) Next() (string, []byte, error) {
+	if !it.HasNext() {
+		return "", nil, errors.New("Range query has no more keys")
+	}
+	key := it.keys[it.next]
+	it.next++
+	return key, it.stub.State[key], nil
+}
+
+func (it *rangeIterator) Close() error {
+	it.closed = true
+	return nil
+}
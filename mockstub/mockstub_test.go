@@ -0,0 +1,165 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		 http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mockstub
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// kvChaincode puts its arguments as key value pairs, fails when asked to and
+// emits an event per pair
+type kvChaincode struct{}
+
+func (kvChaincode) Init(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
+	return nil, nil
+}
+
+func (kvChaincode) Invoke(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
+	for i := 0; i+1 < len(args); i += 2 {
+		err := stub.PutState(args[i], []byte(args[i+1]))
+		if err != nil {
+			return nil, err
+		}
+		err = stub.SetEvent("Put", []byte(args[i]))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if function == "fail" {
+		return nil, errors.New("failed on purpose")
+	}
+	return nil, nil
+}
+
+func (kvChaincode) Query(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
+	if function == "put" {
+		return nil, stub.PutState(args[0], []byte(args[1]))
+	}
+	return stub.GetState(args[0])
+}
+
+func TestFailedInvokeKeepsNoWrites(t *testing.T) {
+	stub := NewMockStub("kv", kvChaincode{})
+	if _, err := stub.MockInvoke("put", []string{"a", "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stub.MockInvoke("fail", []string{"a", "2", "b", "3"}); err == nil {
+		t.Fatal("fail succeeded")
+	}
+	if want := map[string][]byte{"a": []byte("1")}; !reflect.DeepEqual(stub.State, want) {
+		t.Errorf("state is %q after the failed invoke", stub.State)
+	}
+	if len(stub.Events) != 1 {
+		t.Errorf("events are %+v, the failed invoke emitted one", stub.Events)
+	}
+}
+
+func TestQueriesDoNotWrite(t *testing.T) {
+	stub := NewMockStub("kv", kvChaincode{})
+	if _, err := stub.MockQuery("put", []string{"a", "1"}); err == nil {
+		t.Error("a query wrote the state")
+	}
+	if _, ok := stub.State["a"]; ok {
+		t.Error("the query write was kept")
+	}
+}
+
+func TestOnlyTheLastEventIsEmitted(t *testing.T) {
+	stub := NewMockStub("kv", kvChaincode{})
+	if _, err := stub.MockInvoke("put", []string{"a", "1", "b", "2"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(stub.Events) != 1 || string(stub.Events[0].Payload) != "b" || stub.Events[0].TxID != "kv-tx000001" {
+		t.Errorf("events are %+v", stub.Events)
+	}
+}
+
+func TestRangeQueryState(t *testing.T) {
+	stub := NewMockStub("kv", kvChaincode{})
+	if _, err := stub.MockInvoke("put", []string{"c", "3", "a", "1", "b", "2", "d", "4"}); err != nil {
+		t.Fatal(err)
+	}
+	it, err := stub.RangeQueryState("b", "d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Close()
+	var keys []string
+	for it.HasNext() {
+		key, _, err := it.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, key)
+	}
+	if want := []string{"b", "c", "d"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("range b to d returned %v, want %v", keys, want)
+	}
+
+	it, err = stub.RangeQueryState("c", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Close()
+	keys = nil
+	for it.HasNext() {
+		key, _, err := it.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, key)
+	}
+	if want := []string{"c", "d"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("range from c returned %v, want %v", keys, want)
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	stub := NewMockStub("kv", kvChaincode{})
+	if _, err := stub.MockInvoke("put", []string{"a", "1"}); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "ledger.json")
+	if err := stub.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := NewMockStub("kv", kvChaincode{})
+	if err := loaded.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded.State, stub.State) || !reflect.DeepEqual(loaded.Events, stub.Events) {
+		t.Errorf("loaded %q and %+v, saved %q and %+v", loaded.State, loaded.Events, stub.State, stub.Events)
+	}
+}
+
+func TestNewCertificate(t *testing.T) {
+	cert, err := NewCertificate("alice")
+	if err != nil || len(cert) == 0 {
+		t.Fatalf("NewCertificate returned %d bytes, %v", len(cert), err)
+	}
+	stub := NewMockStub("kv", kvChaincode{})
+	stub.SetCaller(cert, map[string]string{"role": "buyer"})
+	if got, _ := stub.GetCallerCertificate(); !reflect.DeepEqual(got, cert) {
+		t.Error("GetCallerCertificate does not return the caller's certificate")
+	}
+	if role, err := stub.ReadCertAttribute("role"); err != nil || string(role) != "buyer" {
+		t.Errorf("role attribute is %q, %v", role, err)
+	}
+}